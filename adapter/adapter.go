@@ -3,6 +3,7 @@ package adapter
 import (
 	"io"
 	"os"
+	"time"
 
 	"github.com/dysodeng/filesystem/storage"
 	"github.com/pkg/errors"
@@ -13,8 +14,13 @@ var (
 	FileNotReadable      = errors.New("file is not readable")
 	FileNotWritable      = errors.New("file is not writable")
 	DirectoryNotWritable = errors.New("directory is not writable")
+	DirectoryNotEmpty    = errors.New("directory is not empty")
+	BucketNotExists      = errors.New("bucket does not exists")
 )
 
+// probeTimeout 初始化探测超时时间
+const probeTimeout = 10 * time.Second
+
 // Adapter 存储适配器接口
 type Adapter interface {
 	// Info 文件/目录信息
@@ -52,7 +58,7 @@ type Adapter interface {
 	// @param dstFile string 目标文件路径
 	// @param srcFile string 原文件路径
 	Move(dstFile, srcFile string) (bool, error)
-  
+
 	// Delete 删除文件
 	// @param file string 文件路径
 	Delete(file string) (bool, error)
@@ -82,3 +88,26 @@ type Adapter interface {
 	// @param fullPath string 文件全路径
 	OriginalPath(fullPath string) string
 }
+
+// Wrapper 装饰器适配器, 返回被装饰的适配器
+type Wrapper interface {
+	Unwrap() Adapter
+}
+
+// As 沿装饰器链查找实现了指定能力接口的适配器
+// @param adapter Adapter 存储适配器
+func As[T any](adapter Adapter) (T, bool) {
+	for adapter != nil {
+		if capability, ok := adapter.(T); ok {
+			return capability, true
+		}
+		wrapper, ok := adapter.(Wrapper)
+		if !ok {
+			break
+		}
+		adapter = wrapper.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
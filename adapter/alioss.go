@@ -1,10 +1,12 @@
 package adapter
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net/http"
 	"net/url"
 	"os"
 	"strconv"
@@ -24,48 +26,117 @@ type AliOssAdapter struct {
 }
 
 type AliOssConfig struct {
-	AccessId       string
-	AccessKey      string
-	EndPoint       string
-	Region         string
-	BucketName     string
-	StayBucketName string
-	StsRoleArn     string
-	IsPrivate      bool
+	AccessId          string
+	AccessKey         string
+	EndPoint          string
+	Region            string
+	BucketName        string
+	StayBucketName    string
+	StsRoleArn        string
+	IsPrivate         bool
+	ResolveVisibility bool                 // Info与List逐个查询对象ACL获取可见性, 每个文件多一次请求; 默认按IsPrivate返回
+	Probe             bool                 // 初始化时检查存储桶是否存在及凭证是否有效
+	Encryption        ServerSideEncryption // 服务端加密, 默认不加密
+	Logger            *slog.Logger         // 日志, 默认不输出
+	MimeDetector      MimeDetector         // 文件类型识别, 默认DefaultMimeDetector
 }
 
-func NewAliOssAdapter(config AliOssConfig) Adapter {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Println(err)
-		}
-	}()
+// validate 校验配置
+func (config AliOssConfig) validate() error {
+	switch {
+	case config.AccessId == "":
+		return &ConfigError{Driver: "oss", Field: "AccessId", Reason: "is required"}
+	case config.AccessKey == "":
+		return &ConfigError{Driver: "oss", Field: "AccessKey", Reason: "is required"}
+	case config.EndPoint == "":
+		return &ConfigError{Driver: "oss", Field: "EndPoint", Reason: "is required"}
+	case config.BucketName == "":
+		return &ConfigError{Driver: "oss", Field: "BucketName", Reason: "is required"}
+	}
+	return config.Encryption.validate("oss")
+}
+
+func init() {
+	Register("oss", newAliOssAdapterFromConfig, dsnParser("access_id", "access_key", "endpoint", "bucket"))
+}
+
+// newAliOssAdapterFromConfig 根据驱动配置创建阿里云OSS存储适配器
+func newAliOssAdapterFromConfig(config map[string]any) (Adapter, error) {
+	reader := newConfigReader("oss", config)
+	ossConfig := AliOssConfig{
+		AccessId:          reader.string("access_id", true),
+		AccessKey:         reader.string("access_key", true),
+		EndPoint:          reader.string("endpoint", true),
+		Region:            reader.string("region", false),
+		BucketName:        reader.string("bucket", true),
+		StayBucketName:    reader.string("stay_bucket", false),
+		StsRoleArn:        reader.string("sts_role_arn", false),
+		IsPrivate:         reader.bool("private"),
+		ResolveVisibility: reader.bool("resolve_visibility"),
+		Probe:             reader.bool("probe"),
+		Encryption:        reader.encryption(),
+	}
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	return NewAliOssAdapter(ossConfig)
+}
 
-	aliOssAdapter := new(AliOssAdapter)
+func NewAliOssAdapter(config AliOssConfig) (Adapter, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	config.Logger = defaultLogger(config.Logger)
+	if config.MimeDetector == nil {
+		config.MimeDetector = DefaultMimeDetector
+	}
 
 	client, err := oss.New(config.EndPoint, config.AccessId, config.AccessKey)
 	if err != nil {
-		panic("ali_oss connect error:" + err.Error())
+		return nil, errors.Wrap(err, "ali_oss connect error")
 	}
 
-	aliOssAdapter.client = client
-
-	bucket, err := aliOssAdapter.client.Bucket(config.BucketName)
+	bucket, err := client.Bucket(config.BucketName)
 	if err != nil {
-		panic("ali_oss bucket error:" + err.Error())
+		return nil, errors.Wrap(err, "ali_oss bucket error")
+	}
+
+	if config.Probe {
+		ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+		defer cancel()
+
+		// 列举一个对象, 同时校验存储桶与凭证
+		err = runWithContext(ctx, func() error {
+			_, err := bucket.ListObjectsV2(oss.MaxKeys(1))
+			return err
+		})
+		if err != nil {
+			if serviceErr, ok := err.(oss.ServiceError); ok && serviceErr.Code == "NoSuchBucket" {
+				return nil, errors.Wrap(BucketNotExists, config.BucketName)
+			}
+			return nil, errors.Wrap(err, "ali_oss probe error")
+		}
 	}
 
-	aliOssAdapter.bucket = bucket
-	aliOssAdapter.config = config
+	return &AliOssAdapter{
+		client: client,
+		bucket: bucket,
+		config: config,
+	}, nil
+}
 
-	return aliOssAdapter
+// mimeDetector 文件类型识别, 供装饰器沿用
+func (adapter *AliOssAdapter) mimeDetector() MimeDetector {
+	return adapter.config.MimeDetector
 }
 
 // Info 文件信息
-func (adapter *AliOssAdapter) Info(file string) (storage.Attribute, error) {
-	res, err := adapter.bucket.GetObjectDetailedMeta(file)
+func (adapter *AliOssAdapter) Info(file string) (_ storage.Attribute, err error) {
+	defer adapter.log(OperationInfo, file, time.Now(), &err)
+	res, err := adapter.bucket.GetObjectDetailedMeta(file, adapter.readOptions()...)
 	if err != nil {
-		return nil, FileNotExists
+		return nil, notExists(err)
 	}
 
 	lastModified, _ := time.Parse(time.RFC1123, res["Last-Modified"][0])
@@ -76,11 +147,14 @@ func (adapter *AliOssAdapter) Info(file string) (storage.Attribute, error) {
 		name = names[len(names)-1]
 	}
 
-	return storage.NewFileAttribute(name, file, "", res["Content-Type"][0], fileSize, lastModified.In(time.Local).Unix()), nil
+	mimeType := storedMimeType(adapter.config.MimeDetector, file, res.Get("Content-Type"))
+	attribute := storage.NewFileAttribute(name, file, adapter.fileVisibility(file), mimeType, fileSize, lastModified.In(time.Local).Unix())
+
+	return withObjectHeader(attribute, res, "X-Oss-"), nil
 }
 
 func (adapter *AliOssAdapter) HasFile(file string) bool {
-	result, err := adapter.bucket.IsObjectExist(file)
+	result, err := adapter.bucket.IsObjectExist(file, adapter.readOptions()...)
 	if err != nil {
 		return false
 	}
@@ -91,26 +165,69 @@ func (adapter *AliOssAdapter) HasDir(file string) bool {
 	return true
 }
 
-func (adapter *AliOssAdapter) Read(file string) (io.ReadCloser, error) {
-	return adapter.bucket.GetObject(file)
+func (adapter *AliOssAdapter) Read(file string) (_ io.ReadCloser, err error) {
+	defer adapter.log(OperationRead, file, time.Now(), &err)
+	return adapter.bucket.GetObject(file, adapter.readOptions()...)
 }
 
-func (adapter *AliOssAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (bool, error) {
-	var options []oss.Option
-	if mimeType != "" {
-		options = []oss.Option{
-			oss.ContentType(mimeType),
+func (adapter *AliOssAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (_ bool, err error) {
+	defer adapter.log(OperationSave, dstFile, time.Now(), &err)
+	if mimeType == "" {
+		if mimeType, srcFile, err = detectReader(adapter.config.MimeDetector, dstFile, srcFile); err != nil {
+			return false, err
 		}
 	}
-
-	if err := adapter.bucket.PutObject(dstFile, srcFile, options...); err != nil {
+	if _, err = adapter.put(dstFile, srcFile, mimeType, "", nil); err != nil {
 		return false, err
 	}
 
 	return true, nil
 }
 
-func (adapter *AliOssAdapter) Cover(sourceImagePath, coverImagePath string, width, height uint) error {
+// SaveWithChecksum 保存文件并校验上传内容, 上传后比对服务端返回的MD5与CRC64
+func (adapter *AliOssAdapter) SaveWithChecksum(dstFile string, srcFile io.Reader, mimeType string) (_ Checksums, err error) {
+	defer adapter.log(OperationSave, dstFile, time.Now(), &err)
+	if mimeType == "" {
+		if mimeType, srcFile, err = detectReader(adapter.config.MimeDetector, dstFile, srcFile); err != nil {
+			return Checksums{}, err
+		}
+	}
+	source, err := newChecksumSource(srcFile)
+	if err != nil {
+		return Checksums{}, err
+	}
+	reported, err := adapter.put(dstFile, source.Reader, mimeType, source.contentMD5(), nil)
+	if err != nil {
+		return Checksums{}, err
+	}
+	return verifyUpload(adapter, dstFile, source.sums(), reported)
+}
+
+// SaveEncoded 保存已压缩的内容, 设置Content-Encoding并在自定义元数据中记录压缩前的大小
+func (adapter *AliOssAdapter) SaveEncoded(dstFile string, srcFile io.Reader, mimeType string, encoding ContentEncoding, size int64) (err error) {
+	defer adapter.log(OperationSave, dstFile, time.Now(), &err)
+	_, err = adapter.put(dstFile, srcFile, mimeType, "", &encodedContent{encoding: encoding, size: size})
+	return err
+}
+
+// put 上传文件, 返回服务端记录的校验值
+func (adapter *AliOssAdapter) put(dstFile string, srcFile io.Reader, mimeType, contentMD5 string, encoded *encodedContent) (Checksums, error) {
+	var header http.Header
+	options := append(adapter.writeOptions(), oss.ContentType(mimeType), oss.GetResponseHeader(&header))
+	if contentMD5 != "" {
+		options = append(options, oss.ContentMD5(contentMD5))
+	}
+	if encoded != nil {
+		options = append(options, oss.ContentEncoding(string(encoded.encoding)), oss.Meta(uncompressedSizeMetadata, encoded.sizeValue()))
+	}
+	if err := adapter.bucket.PutObject(dstFile, srcFile, options...); err != nil {
+		return Checksums{}, err
+	}
+	return Checksums{MD5: header.Get("Content-MD5"), CRC64: header.Get("X-Oss-Hash-Crc64ecma")}, nil
+}
+
+func (adapter *AliOssAdapter) Cover(sourceImagePath, coverImagePath string, width, height uint) (err error) {
+	defer adapter.log(OperationCover, sourceImagePath, time.Now(), &err)
 	style := "image/resize,m_lfit"
 	if width > 0 {
 		style += ",w_" + strconv.Itoa(int(width))
@@ -120,16 +237,98 @@ func (adapter *AliOssAdapter) Cover(sourceImagePath, coverImagePath string, widt
 	}
 	process := fmt.Sprintf("%s|sys/saveas,o_%v", style, base64.URLEncoding.EncodeToString([]byte(coverImagePath)))
 
-	_, err := adapter.bucket.ProcessObject(sourceImagePath, process)
+	_, err = adapter.bucket.ProcessObject(sourceImagePath, process)
 	if err != nil {
-		log.Println(err)
-		return errors.New("缩略图生成失败")
+		return errors.Wrap(err, "缩略图生成失败")
 	}
 
 	return nil
 }
 
-func (adapter *AliOssAdapter) Copy(srcFile, disFile string) (bool, error) {
+func (adapter *AliOssAdapter) Copy(srcFile, disFile string) (_ bool, err error) {
+	defer adapter.log(OperationCopy, srcFile, time.Now(), &err)
+	if err = adapter.copyObject(adapter, srcFile, disFile); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// CopyFrom 从同一地域、同一账号的其他存储桶服务端复制文件
+func (adapter *AliOssAdapter) CopyFrom(src Adapter, srcFile, dstFile string) (err error) {
+	source, ok := src.(*AliOssAdapter)
+	if !ok || source.config.EndPoint != adapter.config.EndPoint || source.config.AccessId != adapter.config.AccessId {
+		return CrossBucketUnsupported
+	}
+	defer adapter.log(OperationCopy, srcFile, time.Now(), &err)
+	return adapter.copyObject(source, srcFile, dstFile)
+}
+
+// copyObject 服务端复制, 源文件超过单次复制上限时使用 UploadPartCopy 分片复制
+func (adapter *AliOssAdapter) copyObject(source *AliOssAdapter, srcFile, dstFile string) error {
+	var header http.Header
+	return copyWithFallback(ossMaxSingleCopySize, func() error {
+		_, err := adapter.bucket.CopyObjectFrom(source.config.BucketName, srcFile, dstFile, adapter.copyOptions(source)...)
+		return err
+	}, func() (int64, error) {
+		var err error
+		if header, err = source.bucket.GetObjectDetailedMeta(srcFile, source.readOptions()...); err != nil {
+			return 0, err
+		}
+		return strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	}, func(size int64) error {
+		return adapter.copyMultipart(source, srcFile, dstFile, size, header)
+	})
+}
+
+// copyMultipart 分片复制, 文件类型与自定义元数据取自源对象, 失败时取消分片上传
+func (adapter *AliOssAdapter) copyMultipart(source *AliOssAdapter, srcFile, dstFile string, size int64, header http.Header) error {
+	options := []oss.Option{
+		// 标准存储
+		oss.StorageClass("Standard"),
+	}
+	for _, name := range contentHeaderNames {
+		if value := header.Get(name); value != "" {
+			options = append(options, oss.SetHeader(name, value))
+		}
+	}
+	for key, value := range userMetadata(header, "X-Oss-") {
+		options = append(options, oss.Meta(key, value))
+	}
+	options = append(options, adapter.writeOptions()...)
+
+	imur, err := adapter.bucket.InitiateMultipartUpload(dstFile, options...)
+	if err != nil {
+		return err
+	}
+
+	// 分片需携带源对象与目标对象各自的SSE-C密钥
+	partOptions := append(source.copySourceOptions(), adapter.readOptions()...)
+	parts := copyParts(size)
+	etags, err := copyPartsConcurrently(parts, func(part copyPart) (string, error) {
+		uploaded, err := adapter.bucket.UploadPartCopy(imur, source.config.BucketName, srcFile, part.start, part.end-part.start+1, part.number, partOptions...)
+		if err != nil {
+			return "", err
+		}
+		return uploaded.ETag, nil
+	})
+	if err == nil {
+		uploaded := make([]oss.UploadPart, len(parts))
+		for i, part := range parts {
+			uploaded[i] = oss.UploadPart{PartNumber: part.number, ETag: etags[i]}
+		}
+		// 禁止覆盖目标同名文件
+		_, err = adapter.bucket.CompleteMultipartUpload(imur, uploaded, oss.ForbidOverWrite(true))
+	}
+	if err != nil {
+		_ = adapter.bucket.AbortMultipartUpload(imur)
+		return err
+	}
+	return nil
+}
+
+// copyOptions 复制选项, 源对象的SSE-C密钥取自源适配器
+func (adapter *AliOssAdapter) copyOptions(source *AliOssAdapter) []oss.Option {
 	options := []oss.Option{
 		// 复制元数据
 		oss.MetadataDirective(oss.MetaCopy),
@@ -138,80 +337,159 @@ func (adapter *AliOssAdapter) Copy(srcFile, disFile string) (bool, error) {
 		// 标准存储
 		oss.StorageClass("Standard"),
 	}
+	options = append(options, adapter.writeOptions()...)
+	return append(options, source.copySourceOptions()...)
+}
 
-	_, err := adapter.bucket.CopyObject(srcFile, disFile, options...)
+// StayBucket 返回访问暂存桶的适配器
+func (adapter *AliOssAdapter) StayBucket() (Adapter, error) {
+	if adapter.config.StayBucketName == "" {
+		return nil, &ConfigError{Driver: "oss", Field: "StayBucketName", Reason: "is required"}
+	}
+	bucket, err := adapter.client.Bucket(adapter.config.StayBucketName)
 	if err != nil {
-		return false, err
+		return nil, errors.Wrap(err, "ali_oss bucket error")
 	}
+	stay := *adapter
+	stay.bucket = bucket
+	stay.config.BucketName, stay.config.StayBucketName = adapter.config.StayBucketName, ""
+	return &stay, nil
+}
 
-	return true, nil
+// Promote 将暂存桶中的文件服务端复制到当前存储桶
+func (adapter *AliOssAdapter) Promote(srcFile, dstFile string) error {
+	stay, err := adapter.StayBucket()
+	if err != nil {
+		return err
+	}
+	return adapter.CopyFrom(stay, srcFile, dstFile)
 }
 
-func (adapter *AliOssAdapter) Move(srcFile, disFile string) (bool, error) {
-	if _, err := adapter.Copy(srcFile, disFile); err != nil {
+// Move 移动文件, 参数顺序与 Adapter 接口一致, 目标在前; 早期版本为源在前, 直接调用时需注意
+func (adapter *AliOssAdapter) Move(disFile, srcFile string) (_ bool, err error) {
+	defer adapter.log(OperationMove, srcFile, time.Now(), &err)
+	if _, err = adapter.Copy(srcFile, disFile); err != nil {
 		return false, err
 	}
-	if _, err := adapter.Delete(srcFile); err != nil {
+	if _, err = adapter.Delete(srcFile); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-func (adapter *AliOssAdapter) Delete(file string) (bool, error) {
-	if err := adapter.bucket.DeleteObject(file); err != nil {
+func (adapter *AliOssAdapter) Delete(file string) (_ bool, err error) {
+	defer adapter.log(OperationDelete, file, time.Now(), &err)
+	if err = adapter.bucket.DeleteObject(file); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-func (adapter *AliOssAdapter) MultipleDelete(fileList []string) (bool, error) {
-	_, err := adapter.bucket.DeleteObjects(fileList)
-	oss.DeleteObjectsQuiet(true)
-	if err != nil {
-		return false, err
+func (adapter *AliOssAdapter) MultipleDelete(fileList []string) (_ bool, err error) {
+	defer adapter.log(OperationMultipleDelete, "", time.Now(), &err)
+	for _, chunk := range batchChunks(fileList, maxBatchKeys) {
+		var results []BatchResult
+		if results, err = adapter.deleteObjects(chunk); err != nil {
+			return false, err
+		}
+		if err = batchError(results); err != nil {
+			return false, err
+		}
 	}
 	return true, nil
 }
 
-func (adapter *AliOssAdapter) MkDir(dir string, mode os.FileMode) (bool, error) {
+// DeleteObjects 批量删除文件, 返回每个文件的删除结果
+func (adapter *AliOssAdapter) DeleteObjects(fileList []string) (_ []BatchResult, err error) {
+	defer adapter.log(OperationMultipleDelete, "", time.Now(), &err)
+	return adapter.deleteObjects(fileList)
+}
+
+func (adapter *AliOssAdapter) deleteObjects(fileList []string) ([]BatchResult, error) {
+	if len(fileList) == 0 {
+		return nil, nil
+	}
+	// 简单模式下SDK不解析响应, 使用详细模式, 未出现在删除列表中的文件视为失败
+	result, err := adapter.bucket.DeleteObjects(fileList, oss.DeleteObjectsQuiet(false))
+	if err != nil {
+		return nil, err
+	}
+	deleted := make(map[string]bool, len(result.DeletedObjects))
+	for _, key := range result.DeletedObjects {
+		deleted[key] = true
+	}
+	failed := make(map[string]error)
+	for _, file := range fileList {
+		if !deleted[file] {
+			failed[file] = errors.New("object was not reported as deleted")
+		}
+	}
+	return batchResults(fileList, failed), nil
+}
+
+func (adapter *AliOssAdapter) MkDir(dir string, mode os.FileMode) (_ bool, err error) {
+	defer adapter.log(OperationMkDir, dir, time.Now(), &err)
 	return true, nil
 }
 
-func (adapter *AliOssAdapter) DeleteDir(dir string) (bool, error) {
+func (adapter *AliOssAdapter) DeleteDir(dir string) (_ bool, err error) {
+	defer adapter.log(OperationDeleteDir, dir, time.Now(), &err)
 	return true, nil
 }
 
-func (adapter *AliOssAdapter) List(dir string, iterable func(attribute storage.Attribute)) error {
+func (adapter *AliOssAdapter) List(dir string, iterable func(attribute storage.Attribute)) (err error) {
+	defer adapter.log(OperationList, dir, time.Now(), &err)
 	dir = strings.TrimRight(dir, "/") + "/"
+	if dir == "/" {
+		dir = ""
+	}
 	prefixDir := oss.Prefix(dir)
 	continueToken := ""
 
-	lsRes, err := adapter.bucket.ListObjectsV2(prefixDir, oss.ContinuationToken(continueToken), oss.Delimiter("/"), oss.FetchOwner(true))
-	if err != nil {
-		return err
-	}
+	for {
+		lsRes, err := adapter.bucket.ListObjectsV2(prefixDir, oss.ContinuationToken(continueToken), oss.Delimiter("/"), oss.FetchOwner(true))
+		if err != nil {
+			return err
+		}
 
-	for _, prefix := range lsRes.CommonPrefixes {
-		names := strings.Split(strings.TrimRight(prefix, "/"), "/")
-		var name string
-		if len(names) > 0 {
-			name = names[len(names)-1]
+		for _, prefix := range lsRes.CommonPrefixes {
+			names := strings.Split(strings.TrimRight(prefix, "/"), "/")
+			var name string
+			if len(names) > 0 {
+				name = names[len(names)-1]
+			}
+			iterable(storage.NewDirectoryAttribute(name, prefix, defaultVisibility(adapter.config.IsPrivate), 0))
 		}
-		iterable(storage.NewDirectoryAttribute(name, prefix, "", 0))
-	}
-	for _, object := range lsRes.Objects {
-		if object.Key == dir {
-			continue
+		for _, object := range lsRes.Objects {
+			if object.Key == dir {
+				continue
+			}
+			names := strings.Split(strings.TrimRight(object.Key, "/"), "/")
+			var name string
+			if len(names) > 0 {
+				name = names[len(names)-1]
+			}
+			mimeType := adapter.config.MimeDetector.Detect(object.Key, nil)
+			iterable(storage.NewFileAttribute(name, object.Key, adapter.fileVisibility(object.Key), mimeType, object.Size, object.LastModified.Unix()).
+				WithETag(strings.Trim(object.ETag, `"`)).
+				WithStorageClass(object.StorageClass))
 		}
-		names := strings.Split(strings.TrimRight(object.Key, "/"), "/")
-		var name string
-		if len(names) > 0 {
-			name = names[len(names)-1]
+
+		if !lsRes.IsTruncated {
+			return nil
 		}
-		iterable(storage.NewFileAttribute(name, object.Key, "", "", object.Size, object.LastModified.Unix()))
+		continueToken = lsRes.NextContinuationToken
 	}
+}
 
-	return nil
+// HealthCheck 列举一个对象检查存储桶是否可访问
+func (adapter *AliOssAdapter) HealthCheck(ctx context.Context) HealthStatus {
+	start := time.Now()
+	err := runWithContext(ctx, func() error {
+		_, err := adapter.bucket.ListObjectsV2(oss.MaxKeys(1))
+		return err
+	})
+	return newHealthStatus(start, err)
 }
 
 func (adapter *AliOssAdapter) FullPath(path string) string {
@@ -232,3 +510,238 @@ func (adapter *AliOssAdapter) OriginalPath(fullPath string) string {
 	}
 	return strings.TrimLeft(u.Path, "/")
 }
+
+// SignURL 生成预签名URL
+func (adapter *AliOssAdapter) SignURL(path, method string, expires time.Duration) (string, error) {
+	signUrl, err := adapter.bucket.SignURL(path, oss.HTTPMethod(method), int64(expires/time.Second))
+	if err != nil {
+		return "", err
+	}
+	return strings.Replace(signUrl, "http://", "https://", 1), nil
+}
+
+// SetVisibility 设置对象ACL
+func (adapter *AliOssAdapter) SetVisibility(path string, visibility storage.Visibility) error {
+	if err := validVisibility(visibility); err != nil {
+		return err
+	}
+	acl := oss.ACLPublicRead
+	if visibility == storage.VisibilityPrivate {
+		acl = oss.ACLPrivate
+	}
+	return adapter.bucket.SetObjectACL(path, acl)
+}
+
+// Visibility 根据对象ACL获取可见性, 继承存储桶ACL(default)时按配置的存储桶可见性
+func (adapter *AliOssAdapter) Visibility(path string) (storage.Visibility, error) {
+	result, err := adapter.bucket.GetObjectACL(path)
+	if err != nil {
+		return "", notExists(err)
+	}
+	switch oss.ACLType(result.ACL) {
+	case oss.ACLPublicRead, oss.ACLPublicReadWrite:
+		return storage.VisibilityPublic, nil
+	case oss.ACLPrivate:
+		return storage.VisibilityPrivate, nil
+	}
+	return defaultVisibility(adapter.config.IsPrivate), nil
+}
+
+// fileVisibility 开启ResolveVisibility时查询对象ACL, 否则或查询失败时按配置的存储桶可见性
+func (adapter *AliOssAdapter) fileVisibility(path string) storage.Visibility {
+	if adapter.config.ResolveVisibility {
+		if visibility, err := adapter.Visibility(path); err == nil {
+			return visibility
+		}
+	}
+	return defaultVisibility(adapter.config.IsPrivate)
+}
+
+// GetMetadata 获取对象自定义元数据(x-oss-meta-*)
+func (adapter *AliOssAdapter) GetMetadata(path string) (map[string]string, error) {
+	header, err := adapter.bucket.GetObjectDetailedMeta(path, adapter.readOptions()...)
+	if err != nil {
+		return nil, notExists(err)
+	}
+	return userMetadata(header, "X-Oss-"), nil
+}
+
+// SetMetadata 以 REPLACE 方式原地复制对象写入自定义元数据, 保留内容类型等标准请求头、存储类型及对象ACL
+func (adapter *AliOssAdapter) SetMetadata(path string, metadata map[string]string, merge bool) error {
+	header, err := adapter.bucket.GetObjectDetailedMeta(path, adapter.readOptions()...)
+	if err != nil {
+		return notExists(err)
+	}
+	metadata, err = resolveMetadata(userMetadata(header, "X-Oss-"), metadata, merge)
+	if err != nil {
+		return err
+	}
+
+	options := []oss.Option{oss.MetadataDirective(oss.MetaReplace)}
+	for _, name := range contentHeaderNames {
+		if value := header.Get(name); value != "" {
+			options = append(options, oss.SetHeader(name, value))
+		}
+	}
+	if storageClass := header.Get("X-Oss-Storage-Class"); storageClass != "" {
+		options = append(options, oss.StorageClass(oss.StorageClassType(storageClass)))
+	}
+	// 复制时对象ACL会重置为继承存储桶, 需显式保留
+	if result, err := adapter.bucket.GetObjectACL(path); err == nil && result.ACL != "" && result.ACL != "default" {
+		options = append(options, oss.ObjectACL(oss.ACLType(result.ACL)))
+	}
+	for key, value := range metadata {
+		options = append(options, oss.Meta(key, value))
+	}
+	options = append(options, adapter.writeOptions()...)
+	options = append(options, adapter.copySourceOptions()...)
+
+	_, err = adapter.bucket.CopyObject(path, path, options...)
+	return err
+}
+
+// PutTags 设置对象标签
+func (adapter *AliOssAdapter) PutTags(path string, tags map[string]string) error {
+	if err := validTags(tags); err != nil {
+		return err
+	}
+	tagging := oss.Tagging{Tags: make([]oss.Tag, 0, len(tags))}
+	for _, key := range sortedTagKeys(tags) {
+		tagging.Tags = append(tagging.Tags, oss.Tag{Key: key, Value: tags[key]})
+	}
+	return notExists(adapter.bucket.PutObjectTagging(path, tagging))
+}
+
+// GetTags 获取对象标签
+func (adapter *AliOssAdapter) GetTags(path string) (map[string]string, error) {
+	result, err := adapter.bucket.GetObjectTagging(path)
+	if err != nil {
+		return nil, notExists(err)
+	}
+	tags := make(map[string]string, len(result.Tags))
+	for _, tag := range result.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
+
+// DeleteTags 删除对象标签
+func (adapter *AliOssAdapter) DeleteTags(path string) error {
+	return notExists(adapter.bucket.DeleteObjectTagging(path))
+}
+
+// ListVersions 列出对象的全部版本及删除标记
+func (adapter *AliOssAdapter) ListVersions(path string) ([]ObjectVersion, error) {
+	var versions []ObjectVersion
+	keyMarker, versionIdMarker := "", ""
+	for {
+		result, err := adapter.bucket.ListObjectVersions(oss.Prefix(path), oss.KeyMarker(keyMarker), oss.VersionIdMarker(versionIdMarker))
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range result.ObjectVersions {
+			if version.Key == path {
+				versions = append(versions, ObjectVersion{
+					VersionID:    version.VersionId,
+					IsLatest:     version.IsLatest,
+					Size:         version.Size,
+					ETag:         strings.Trim(version.ETag, `"`),
+					LastModified: version.LastModified,
+				})
+			}
+		}
+		for _, marker := range result.ObjectDeleteMarkers {
+			if marker.Key == path {
+				versions = append(versions, ObjectVersion{
+					VersionID:      marker.VersionId,
+					IsLatest:       marker.IsLatest,
+					IsDeleteMarker: true,
+					LastModified:   marker.LastModified,
+				})
+			}
+		}
+		if !result.IsTruncated {
+			break
+		}
+		keyMarker, versionIdMarker = result.NextKeyMarker, result.NextVersionIdMarker
+	}
+	if len(versions) == 0 {
+		return nil, FileNotExists
+	}
+	return sortVersions(versions), nil
+}
+
+// ReadVersion 读取对象的指定版本
+func (adapter *AliOssAdapter) ReadVersion(path, versionID string) (io.ReadCloser, error) {
+	reader, err := adapter.bucket.GetObject(path, append(adapter.readOptions(), oss.VersionId(versionID))...)
+	if err != nil {
+		return nil, notExists(err)
+	}
+	return reader, nil
+}
+
+// RestoreVersion 将指定版本复制为当前版本
+func (adapter *AliOssAdapter) RestoreVersion(path, versionID string) error {
+	options := []oss.Option{oss.VersionId(versionID)}
+	options = append(options, adapter.writeOptions()...)
+	options = append(options, adapter.copySourceOptions()...)
+	_, err := adapter.bucket.CopyObject(path, path, options...)
+	return notExists(err)
+}
+
+// DeleteVersion 永久删除对象的指定版本
+func (adapter *AliOssAdapter) DeleteVersion(path, versionID string) error {
+	return notExists(adapter.bucket.DeleteObject(path, oss.VersionId(versionID)))
+}
+
+// WithServerSideEncryption 返回使用指定服务端加密方式的适配器副本
+func (adapter *AliOssAdapter) WithServerSideEncryption(sse ServerSideEncryption) Adapter {
+	clone := *adapter
+	clone.config.Encryption = sse
+	return &clone
+}
+
+// writeOptions 上传及复制目标使用的服务端加密选项
+func (adapter *AliOssAdapter) writeOptions() []oss.Option {
+	sse := adapter.config.Encryption
+	switch sse.Type {
+	case SSES3:
+		return []oss.Option{oss.ServerSideEncryption("AES256")}
+	case SSEKMS:
+		options := []oss.Option{oss.ServerSideEncryption("KMS")}
+		if sse.KMSKeyID != "" {
+			options = append(options, oss.ServerSideEncryptionKeyID(sse.KMSKeyID))
+		}
+		return options
+	case SSEC:
+		return adapter.readOptions()
+	}
+	return nil
+}
+
+// readOptions 读取SSE-C加密对象时需携带的客户密钥
+func (adapter *AliOssAdapter) readOptions() []oss.Option {
+	if adapter.config.Encryption.Type != SSEC {
+		return nil
+	}
+	key, keyMD5 := adapter.config.Encryption.customerKey()
+	return []oss.Option{oss.SSECAlgorithm("AES256"), oss.SSECKey(key), oss.SSECKeyMd5(keyMD5)}
+}
+
+// copySourceOptions 复制SSE-C加密的源对象时需携带的客户密钥
+func (adapter *AliOssAdapter) copySourceOptions() []oss.Option {
+	if adapter.config.Encryption.Type != SSEC {
+		return nil
+	}
+	key, keyMD5 := adapter.config.Encryption.customerKey()
+	return []oss.Option{
+		oss.SetHeader("X-Oss-Copy-Source-Server-Side-Encryption-Customer-Algorithm", "AES256"),
+		oss.SetHeader("X-Oss-Copy-Source-Server-Side-Encryption-Customer-Key", key),
+		oss.SetHeader("X-Oss-Copy-Source-Server-Side-Encryption-Customer-Key-MD5", keyMD5),
+	}
+}
+
+// log 记录操作日志
+func (adapter *AliOssAdapter) log(operation, path string, start time.Time, err *error) {
+	logOperation(adapter.config.Logger, "oss", operation, path, start, err)
+}
@@ -0,0 +1,48 @@
+package adapter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestAliOssAdapterMoveArgumentOrder(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.Header.Get("X-Oss-Copy-Source"))
+		mu.Unlock()
+		if r.Method == http.MethodPut {
+			_, _ = w.Write([]byte(`<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	oss, err := NewAliOssAdapter(AliOssConfig{
+		AccessId:   "id",
+		AccessKey:  "key",
+		EndPoint:   server.URL,
+		BucketName: "bucket",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 与 Adapter 接口一致, 目标在前、源在后
+	if _, err = oss.Move("dst.txt", "src.txt"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"PUT /bucket/dst.txt /bucket/src.txt", "DELETE /bucket/src.txt "}
+	if len(requests) != len(want) {
+		t.Fatalf("got requests %q, want %q", requests, want)
+	}
+	for i := range want {
+		if requests[i] != want[i] {
+			t.Errorf("request %d: got %q, want %q", i, requests[i], want[i])
+		}
+	}
+}
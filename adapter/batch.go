@@ -0,0 +1,219 @@
+package adapter
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var BatchDeleteUnsupported = errors.New("batch delete is not supported by the adapter")
+
+// maxBatchKeys 云存储单次批量删除的文件数上限
+const maxBatchKeys = 1000
+
+// defaultBatchConcurrency 默认批量操作并发数
+const defaultBatchConcurrency = 8
+
+// BatchResult 批量操作中单个文件的结果
+type BatchResult struct {
+	Path string // 文件路径, 复制与移动时为源文件路径
+	Err  error  // 失败原因, 成功时为nil
+}
+
+// BatchPair 批量复制、移动的源文件与目标文件
+type BatchPair struct {
+	Src string // 源文件路径
+	Dst string // 目标文件路径
+}
+
+type BatchOptions struct {
+	Concurrency int // 并发数, 默认8
+}
+
+// BatchDeleteAdapter 支持原生批量删除的存储适配器
+type BatchDeleteAdapter interface {
+	// DeleteObjects 批量删除文件, 单次不超过1000个
+	// 请求整体失败时返回error, 否则按文件返回删除结果
+	// @param fileList []string 文件列表
+	DeleteObjects(fileList []string) ([]BatchResult, error)
+}
+
+// BatchDelete 批量删除文件, 按输入顺序返回每个文件的结果
+// 装饰器链中只有重试、观测装饰器时经由这些装饰器使用云存储原生批量删除, 按1000个分批并发请求;
+// 否则逐个调用 Delete, 以便加密、压缩、回收站等装饰器处理各自的元数据
+// @param adapter Adapter 存储适配器
+// @param fileList []string 文件列表
+// @param options BatchOptions 批量选项
+func BatchDelete(adapter Adapter, fileList []string, options BatchOptions) []BatchResult {
+	results := make([]BatchResult, len(fileList))
+	for i, file := range fileList {
+		results[i].Path = file
+	}
+
+	deleter := batchDeleter(adapter)
+	if deleter == nil {
+		RunBatch(len(fileList), options, func(i int) {
+			_, results[i].Err = adapter.Delete(fileList[i])
+		})
+		return results
+	}
+
+	chunks := batchChunks(fileList, maxBatchKeys)
+	RunBatch(len(chunks), options, func(i int) {
+		offset := i * maxBatchKeys
+		chunkResults, err := deleter.DeleteObjects(chunks[i])
+		if err != nil {
+			for j := range chunks[i] {
+				results[offset+j].Err = err
+			}
+			return
+		}
+		for j, result := range chunkResults {
+			results[offset+j].Err = result.Err
+		}
+	})
+	return results
+}
+
+// BatchCopy 批量复制文件, 按输入顺序返回每个文件的结果
+// @param adapter Adapter 存储适配器
+// @param pairs []BatchPair 源文件与目标文件
+// @param options BatchOptions 批量选项
+func BatchCopy(adapter Adapter, pairs []BatchPair, options BatchOptions) []BatchResult {
+	results := make([]BatchResult, len(pairs))
+	RunBatch(len(pairs), options, func(i int) {
+		results[i].Path = pairs[i].Src
+		_, results[i].Err = adapter.Copy(pairs[i].Src, pairs[i].Dst)
+	})
+	return results
+}
+
+// BatchMove 批量移动文件, 按输入顺序返回每个文件的结果
+// @param adapter Adapter 存储适配器
+// @param pairs []BatchPair 源文件与目标文件
+// @param options BatchOptions 批量选项
+func BatchMove(adapter Adapter, pairs []BatchPair, options BatchOptions) []BatchResult {
+	results := make([]BatchResult, len(pairs))
+	RunBatch(len(pairs), options, func(i int) {
+		results[i].Path = pairs[i].Src
+		_, results[i].Err = adapter.Move(pairs[i].Dst, pairs[i].Src)
+	})
+	return results
+}
+
+// BatchFailed 返回批量操作中失败的结果
+func BatchFailed(results []BatchResult) []BatchResult {
+	var failed []BatchResult
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// batchDeleter 底层适配器支持原生批量删除时返回装饰器链最外层的实现, 重试、观测装饰器转发至底层适配器;
+// 遇到维护元数据的装饰器时返回nil
+func batchDeleter(adapter Adapter) BatchDeleteAdapter {
+	if _, ok := bareAdapter(adapter).(BatchDeleteAdapter); !ok {
+		return nil
+	}
+	deleter, _ := adapter.(BatchDeleteAdapter)
+	return deleter
+}
+
+// RunBatch 以有限并发执行n个任务, 全部任务完成后返回
+// @param n int 任务数
+// @param options BatchOptions 并发选项, 并发数默认8
+// @param task func(i int) 执行第i个任务, 会被并发调用
+func RunBatch(n int, options BatchOptions, task func(i int)) {
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+
+	tasks := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				task(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		tasks <- i
+	}
+	close(tasks)
+	wg.Wait()
+}
+
+// batchChunks 按数量上限切分文件列表
+func batchChunks(fileList []string, size int) [][]string {
+	var chunks [][]string
+	for len(fileList) > size {
+		chunks = append(chunks, fileList[:size])
+		fileList = fileList[size:]
+	}
+	if len(fileList) > 0 {
+		chunks = append(chunks, fileList)
+	}
+	return chunks
+}
+
+// batchError 返回批量结果中的第一个错误
+func batchError(results []BatchResult) error {
+	for _, result := range results {
+		if result.Err != nil {
+			return errors.Wrap(result.Err, result.Path)
+		}
+	}
+	return nil
+}
+
+// batchResults 按删除失败的文件生成结果, 未列出的文件视为成功
+func batchResults(fileList []string, failed map[string]error) []BatchResult {
+	results := make([]BatchResult, len(fileList))
+	for i, file := range fileList {
+		results[i] = BatchResult{Path: file, Err: failed[file]}
+	}
+	return results
+}
+
+// BatchDelete 批量删除挂载管理器路径, 按适配器分组后分别批量删除
+func (manager *MountManager) BatchDelete(fileList []string, options BatchOptions) []BatchResult {
+	results := make([]BatchResult, len(fileList))
+	var order []Adapter
+	groups := make(map[Adapter][]int)
+	paths := make([]string, len(fileList))
+	for i, file := range fileList {
+		results[i].Path = file
+		mp, err := manager.resolve(file)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		if _, ok := groups[mp.adapter]; !ok {
+			order = append(order, mp.adapter)
+		}
+		groups[mp.adapter] = append(groups[mp.adapter], i)
+		paths[i] = mp.path
+	}
+
+	for _, adapter := range order {
+		indexes := groups[adapter]
+		group := make([]string, len(indexes))
+		for j, i := range indexes {
+			group[j] = paths[i]
+		}
+		for j, result := range BatchDelete(adapter, group, options) {
+			results[indexes[j]].Err = result.Err
+		}
+	}
+	return results
+}
@@ -0,0 +1,254 @@
+package adapter
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"hash/crc64"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+var (
+	ChecksumMismatch    = errors.New("checksum mismatch")
+	ChecksumUnavailable = errors.New("no checksum available for verification")
+	ChecksumUnsupported = errors.New("upload checksum is not supported by the adapter")
+)
+
+// checksumSuffix 本地存储校验值文件后缀
+const checksumSuffix = ".fssum"
+
+// crc64Table OSS、COS使用的CRC64-ECMA
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// Checksums 文件内容校验值, 编码与 storage.FileAttribute 一致
+type Checksums struct {
+	MD5    string `json:"md5,omitempty"`    // base64编码
+	CRC64  string `json:"crc64,omitempty"`  // CRC64-ECMA, 十进制
+	SHA256 string `json:"sha256,omitempty"` // base64编码
+}
+
+// ChecksumAdapter 支持上传完整性校验的存储适配器
+type ChecksumAdapter interface {
+	// SaveWithChecksum 保存文件, 上传时边读边计算MD5、CRC64、SHA-256
+	// 内容可Seek时预先计算并携带Content-MD5, 由服务端拒绝损坏的上传; 上传后比对服务端返回的校验值,
+	// 不一致时删除已上传的文件并返回 ChecksumMismatch
+	// @param dstFile string 目标文件路径
+	// @param srcFile io.Reader 文件内容
+	// @param mimeType string 文件类型
+	SaveWithChecksum(dstFile string, srcFile io.Reader, mimeType string) (Checksums, error)
+}
+
+// SaveWithChecksum 保存文件并返回内容校验值
+// 装饰器链中只有重试、观测装饰器时使用适配器的上传校验; 否则只计算写入装饰器前的内容校验值
+// @param adapter Adapter 存储适配器
+// @param dstFile string 目标文件路径
+// @param srcFile io.Reader 文件内容
+// @param mimeType string 文件类型
+func SaveWithChecksum(adapter Adapter, dstFile string, srcFile io.Reader, mimeType string) (Checksums, error) {
+	if saver := checksumSaver(adapter); saver != nil {
+		return saver.SaveWithChecksum(dstFile, srcFile, mimeType)
+	}
+
+	hashes := newChecksumHashes()
+	if _, err := adapter.Save(dstFile, io.TeeReader(srcFile, hashes), mimeType); err != nil {
+		return Checksums{}, err
+	}
+	return hashes.sums(), nil
+}
+
+// checksumSaver 底层适配器支持上传校验时返回装饰器链最外层的实现, 重试、观测装饰器转发至底层适配器;
+// 遇到其他装饰器时返回nil
+func checksumSaver(adapter Adapter) ChecksumAdapter {
+	if _, ok := bareAdapter(adapter).(ChecksumAdapter); !ok {
+		return nil
+	}
+	saver, _ := adapter.(ChecksumAdapter)
+	return saver
+}
+
+// ReadVerified 读取文件, 读取结束时按文件属性中的校验值校验内容
+// 依次使用SHA-256、CRC64、MD5, 都没有时使用非分片上传且未使用KMS、SSE-C加密的ETag;
+// 内容不一致时最后一次 Read 返回 ChecksumMismatch, 没有可用的校验值时返回 ChecksumUnavailable
+// @param adapter Adapter 存储适配器
+// @param file string 文件路径
+func ReadVerified(adapter Adapter, file string) (io.ReadCloser, error) {
+	attribute, err := adapter.Info(file)
+	if err != nil {
+		return nil, err
+	}
+	fileAttribute, ok := attribute.(*storage.FileAttribute)
+	if !ok {
+		return nil, FileNotExists
+	}
+
+	verifier := &verifyReader{file: file}
+	switch {
+	case fileAttribute.SHA256() != "":
+		verifier.algorithm, verifier.expected, verifier.hash = "sha256", fileAttribute.SHA256(), sha256.New()
+	case fileAttribute.CRC64() != "":
+		verifier.algorithm, verifier.expected, verifier.hash = "crc64", fileAttribute.CRC64(), crc64.New(crc64Table)
+	case fileAttribute.ContentMD5() != "":
+		verifier.algorithm, verifier.expected, verifier.hash = "md5", fileAttribute.ContentMD5(), md5.New()
+	default:
+		etagMD5 := etagContentMD5(fileAttribute.ETag())
+		if etagMD5 == "" || fileAttribute.ServerSideEncryption() == string(SSEKMS) || fileAttribute.ServerSideEncryption() == string(SSEC) {
+			return nil, errors.Wrap(ChecksumUnavailable, file)
+		}
+		verifier.algorithm, verifier.expected, verifier.hash = "md5", etagMD5, md5.New()
+	}
+
+	reader, err := adapter.Read(file)
+	if err != nil {
+		return nil, err
+	}
+	verifier.ReadCloser = reader
+	return verifier, nil
+}
+
+// checksumHashes 同时计算MD5、CRC64、SHA-256
+type checksumHashes struct {
+	md5    hash.Hash
+	crc64  hash.Hash64
+	sha256 hash.Hash
+}
+
+func newChecksumHashes() *checksumHashes {
+	return &checksumHashes{md5: md5.New(), crc64: crc64.New(crc64Table), sha256: sha256.New()}
+}
+
+func (hashes *checksumHashes) Write(p []byte) (int, error) {
+	_, _ = hashes.md5.Write(p)
+	_, _ = hashes.crc64.Write(p)
+	_, _ = hashes.sha256.Write(p)
+	return len(p), nil
+}
+
+// sums 已写入内容的校验值
+func (hashes *checksumHashes) sums() Checksums {
+	return Checksums{
+		MD5:    base64.StdEncoding.EncodeToString(hashes.md5.Sum(nil)),
+		CRC64:  strconv.FormatUint(hashes.crc64.Sum64(), 10),
+		SHA256: base64.StdEncoding.EncodeToString(hashes.sha256.Sum(nil)),
+	}
+}
+
+// checksumSource 上传内容及其校验值
+// 可Seek的内容先完整计算校验值再回到原位置, 以便上传时携带Content-MD5; 否则在上传过程中边读边计算
+type checksumSource struct {
+	io.Reader
+	hashes      *checksumHashes
+	precomputed bool
+}
+
+func newChecksumSource(src io.Reader) (*checksumSource, error) {
+	hashes := newChecksumHashes()
+	if seeker, ok := src.(io.ReadSeeker); ok {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			if _, err = io.Copy(hashes, seeker); err != nil {
+				return nil, err
+			}
+			if _, err = seeker.Seek(offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return &checksumSource{Reader: src, hashes: hashes, precomputed: true}, nil
+		}
+	}
+	return &checksumSource{Reader: io.TeeReader(src, hashes), hashes: hashes}, nil
+}
+
+// contentMD5 上传前已知的Content-MD5, 不可Seek的内容为空
+func (source *checksumSource) contentMD5() string {
+	if !source.precomputed {
+		return ""
+	}
+	return source.hashes.sums().MD5
+}
+
+// sums 上传内容的校验值, 边读边计算时需在上传完成后调用
+func (source *checksumSource) sums() Checksums {
+	return source.hashes.sums()
+}
+
+// verifyUpload 比对本地计算与服务端返回的校验值, 不一致时删除已上传的文件
+func verifyUpload(adapter Adapter, file string, local, reported Checksums) (Checksums, error) {
+	checks := []struct{ algorithm, sent, stored string }{
+		{"md5", local.MD5, reported.MD5},
+		{"crc64", local.CRC64, reported.CRC64},
+		{"sha256", local.SHA256, reported.SHA256},
+	}
+	for _, check := range checks {
+		if check.sent != "" && check.stored != "" && check.sent != check.stored {
+			_, _ = adapter.Delete(file)
+			return Checksums{}, errors.Wrapf(ChecksumMismatch, "%s %s: sent %s, stored %s", file, check.algorithm, check.sent, check.stored)
+		}
+	}
+	return local, nil
+}
+
+// etagIsContentMD5 该加密方式下非分片上传的ETag是否为内容MD5, KMS与SSE-C加密时不是
+func (sse ServerSideEncryption) etagIsContentMD5() bool {
+	return sse.Type != SSEKMS && sse.Type != SSEC
+}
+
+// etagContentMD5 非分片上传的ETag为内容MD5的十六进制, 转换为base64编码; 其余ETag返回空
+func etagContentMD5(etag string) string {
+	sum, err := hex.DecodeString(strings.Trim(etag, `"`))
+	if err != nil || len(sum) != md5.Size {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(sum)
+}
+
+// verifyReader 读取结束时校验内容的读取器
+type verifyReader struct {
+	io.ReadCloser
+	file      string
+	algorithm string
+	expected  string
+	hash      hash.Hash
+}
+
+func (reader *verifyReader) Read(p []byte) (int, error) {
+	n, err := reader.ReadCloser.Read(p)
+	_, _ = reader.hash.Write(p[:n])
+	if err == io.EOF {
+		if actual := reader.sum(); actual != reader.expected {
+			return n, errors.Wrapf(ChecksumMismatch, "%s %s: expected %s, got %s", reader.file, reader.algorithm, reader.expected, actual)
+		}
+	}
+	return n, err
+}
+
+// sum 已读取内容的校验值, 编码与 storage.FileAttribute 一致
+func (reader *verifyReader) sum() string {
+	if reader.algorithm == "crc64" {
+		return strconv.FormatUint(binary.BigEndian.Uint64(reader.hash.Sum(nil)), 10)
+	}
+	return base64.StdEncoding.EncodeToString(reader.hash.Sum(nil))
+}
+
+// SaveWithChecksum 保存挂载点内的文件并返回内容校验值
+func (manager *MountManager) SaveWithChecksum(dstFile string, srcFile io.Reader, mimeType string) (Checksums, error) {
+	mp, err := manager.resolve(dstFile)
+	if err != nil {
+		return Checksums{}, err
+	}
+	return SaveWithChecksum(mp.adapter, mp.path, srcFile, mimeType)
+}
+
+// ReadVerified 读取挂载点内的文件并校验内容
+func (manager *MountManager) ReadVerified(file string) (io.ReadCloser, error) {
+	mp, err := manager.resolve(file)
+	if err != nil {
+		return nil, err
+	}
+	return ReadVerified(mp.adapter, mp.path)
+}
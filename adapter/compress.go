@@ -0,0 +1,478 @@
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+var (
+	CompressionUnsupported     = errors.New("compression encoding is not supported")
+	CompressedCoverUnsupported = errors.New("cover is not supported for compressed files")
+)
+
+const compressionSuffix = ".fscmp"
+
+// uncompressedSizeMetadata 对象存储中记录压缩前大小的自定义元数据
+const uncompressedSizeMetadata = "uncompressed-size"
+
+// ContentEncoding 压缩编码
+type ContentEncoding string
+
+const (
+	EncodingGzip ContentEncoding = "gzip"
+	EncodingZstd ContentEncoding = "zstd"
+)
+
+// magic 压缩数据的起始字节
+func (encoding ContentEncoding) magic() []byte {
+	switch encoding {
+	case EncodingGzip:
+		return []byte{0x1f, 0x8b}
+	case EncodingZstd:
+		return []byte{0x28, 0xb5, 0x2f, 0xfd}
+	}
+	return nil
+}
+
+// ContentEncodingAdapter 支持以Content-Encoding保存压缩内容的存储适配器
+type ContentEncodingAdapter interface {
+	// SaveEncoded 保存已压缩的内容, 设置Content-Encoding并在自定义元数据中记录压缩前的大小
+	// @param dstFile string 目标文件路径
+	// @param srcFile io.Reader 压缩后的内容
+	// @param mimeType string 压缩前的文件类型
+	// @param encoding ContentEncoding 压缩编码
+	// @param size int64 压缩前的大小
+	SaveEncoded(dstFile string, srcFile io.Reader, mimeType string, encoding ContentEncoding, size int64) error
+}
+
+// encodedContent 以Content-Encoding保存的压缩内容信息
+type encodedContent struct {
+	encoding ContentEncoding
+	size     int64
+}
+
+// sizeValue 压缩前大小的元数据值
+func (content *encodedContent) sizeValue() string {
+	return strconv.FormatInt(content.size, 10)
+}
+
+// contentEncoder 底层适配器支持内容编码时返回装饰器链最外层的实现, 重试、观测装饰器转发至底层适配器;
+// 遇到其他装饰器时返回nil
+func contentEncoder(adapter Adapter) ContentEncodingAdapter {
+	if _, ok := bareAdapter(adapter).(ContentEncodingAdapter); !ok {
+		return nil
+	}
+	encoder, _ := adapter.(ContentEncodingAdapter)
+	return encoder
+}
+
+// defaultCompressibleTypes 默认压缩的文件类型
+var defaultCompressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/x-ndjson",
+	"application/xml",
+	"application/javascript",
+	"application/x-yaml",
+	"image/svg+xml",
+}
+
+// CompressedAdapter 透明压缩装饰器
+// Save 时按文件类型规则压缩, Read 时解压, Info 返回压缩前的大小。
+// 对象存储以Content-Encoding保存压缩内容, 压缩前的大小记录在自定义元数据中, 文件地址与签名地址可由浏览器直接解压;
+// List 返回存储的大小, 不逐个查询元数据。
+// 本地存储或经其他装饰器包装的存储无法携带内容编码, 压缩编码、原始大小与文件类型保存在 .sidecars/<文件路径>.fscmp 元数据文件中,
+// List 同样返回压缩前的大小。没有压缩标记的文件按原样读取。
+type CompressedAdapter struct {
+	adapter   Adapter
+	config    CompressionConfig
+	encoder   ContentEncodingAdapter // 底层不支持内容编码时为nil, 使用元数据文件
+	encodings sidecar
+}
+
+type CompressionConfig struct {
+	Encoding     ContentEncoding // 压缩编码, 默认gzip
+	Level        int             // 压缩级别, 0为编码默认级别; gzip 1-9, zstd 1-22
+	MimeTypes    []string        // 需要压缩的文件类型, 支持 text/* 形式的通配, 默认为常见文本类型
+	TempDir      string          // 对象存储上传前暂存压缩内容的本地目录, 默认系统临时目录
+	MimeDetector MimeDetector    // 文件类型识别, 默认沿用被装饰适配器的配置
+}
+
+// compressionRecord 压缩元数据
+type compressionRecord struct {
+	Encoding ContentEncoding `json:"encoding"`
+	Size     int64           `json:"size"`
+	MimeType string          `json:"mime_type"`
+}
+
+func NewCompressedAdapter(adapter Adapter, config CompressionConfig) (Adapter, error) {
+	switch config.Encoding {
+	case "":
+		config.Encoding = EncodingGzip
+	case EncodingGzip, EncodingZstd:
+	default:
+		return nil, &ConfigError{Driver: "compression", Field: "Encoding", Reason: "is invalid: " + string(config.Encoding)}
+	}
+	if config.Encoding == EncodingGzip && (config.Level < 0 || config.Level > gzip.BestCompression) {
+		return nil, &ConfigError{Driver: "compression", Field: "Level", Reason: "must be between 1 and 9 for gzip"}
+	}
+	if config.MimeTypes == nil {
+		config.MimeTypes = defaultCompressibleTypes
+	}
+	if config.MimeDetector == nil {
+		config.MimeDetector = resolveMimeDetector(adapter)
+	}
+	return &CompressedAdapter{
+		adapter:   adapter,
+		config:    config,
+		encoder:   contentEncoder(adapter),
+		encodings: sidecar{adapter: adapter, suffix: compressionSuffix, dir: sidecarsDir},
+	}, nil
+}
+
+// Unwrap 返回被装饰的适配器
+func (adapter *CompressedAdapter) Unwrap() Adapter {
+	return adapter.adapter
+}
+
+// compressible 文件类型是否需要压缩
+func (adapter *CompressedAdapter) compressible(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range adapter.config.MimeTypes {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// record 读取压缩元数据, 未压缩文件返回nil
+func (adapter *CompressedAdapter) record(file string) (*compressionRecord, error) {
+	if adapter.encoder != nil {
+		attribute, err := adapter.adapter.Info(file)
+		if err != nil {
+			return nil, err
+		}
+		return encodedRecord(attribute), nil
+	}
+
+	var record compressionRecord
+	if err := adapter.encodings.load(file, &record); err != nil {
+		if errors.Is(err, FileNotExists) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// encodedRecord 从对象的Content-Encoding与自定义元数据还原压缩信息, 未压缩文件返回nil
+func encodedRecord(attribute storage.Attribute) *compressionRecord {
+	fileAttribute, ok := attribute.(*storage.FileAttribute)
+	if !ok {
+		return nil
+	}
+	encoding := ContentEncoding(fileAttribute.ContentEncoding())
+	if encoding.magic() == nil {
+		return nil
+	}
+	size, err := strconv.ParseInt(fileAttribute.Metadata()[uncompressedSizeMetadata], 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &compressionRecord{Encoding: encoding, Size: size, MimeType: fileAttribute.MimeType()}
+}
+
+// logical 将属性中的大小与类型还原为压缩前的信息
+func (adapter *CompressedAdapter) logical(attribute storage.Attribute) (storage.Attribute, error) {
+	fileAttribute, ok := attribute.(*storage.FileAttribute)
+	if !ok {
+		return attribute, nil
+	}
+	var record *compressionRecord
+	if adapter.encoder != nil {
+		record = encodedRecord(fileAttribute)
+	} else {
+		var err error
+		if record, err = adapter.record(fileAttribute.Path()); err != nil {
+			return attribute, err
+		}
+	}
+	if record == nil {
+		return attribute, nil
+	}
+	// 存储的校验值对应压缩后的内容
+	return fileAttribute.WithFileSize(record.Size).WithMimeType(record.MimeType).WithoutChecksums(), nil
+}
+
+// newWriter 创建压缩写入器
+func (adapter *CompressedAdapter) newWriter(w io.Writer) (io.WriteCloser, error) {
+	switch adapter.config.Encoding {
+	case EncodingZstd:
+		level := zstd.SpeedDefault
+		if adapter.config.Level > 0 {
+			level = zstd.EncoderLevelFromZstd(adapter.config.Level)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(level))
+	default:
+		level := adapter.config.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	}
+}
+
+func (adapter *CompressedAdapter) Info(file string) (storage.Attribute, error) {
+	attribute, err := adapter.adapter.Info(file)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.logical(attribute)
+}
+
+func (adapter *CompressedAdapter) HasFile(file string) bool {
+	return adapter.adapter.HasFile(file)
+}
+
+func (adapter *CompressedAdapter) HasDir(file string) bool {
+	return adapter.adapter.HasDir(file)
+}
+
+func (adapter *CompressedAdapter) Read(file string) (io.ReadCloser, error) {
+	record, err := adapter.record(file)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := adapter.adapter.Read(file)
+	if err != nil || record == nil {
+		return reader, err
+	}
+
+	// 对象存储客户端可能已按Content-Encoding透明解压gzip, 此时内容不再以压缩标记开头
+	buffered := bufio.NewReader(reader)
+	if magic := record.Encoding.magic(); magic != nil {
+		if head, _ := buffered.Peek(len(magic)); adapter.encoder != nil && !bytes.Equal(head, magic) {
+			return &decompressReader{ReadCloser: io.NopCloser(buffered), source: reader}, nil
+		}
+	}
+
+	var decoder io.ReadCloser
+	switch record.Encoding {
+	case EncodingGzip:
+		decoder, err = gzip.NewReader(buffered)
+	case EncodingZstd:
+		var zstdDecoder *zstd.Decoder
+		if zstdDecoder, err = zstd.NewReader(buffered); err == nil {
+			decoder = zstdDecoder.IOReadCloser()
+		}
+	default:
+		err = errors.Wrap(CompressionUnsupported, string(record.Encoding))
+	}
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+
+	return &decompressReader{ReadCloser: decoder, source: reader}, nil
+}
+
+// Save 符合规则的文件流式压缩后保存, 其余文件原样保存
+func (adapter *CompressedAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (bool, error) {
+	// 压缩规则依赖文件类型, 未指定时在压缩前识别
+	if mimeType == "" {
+		var err error
+		if mimeType, srcFile, err = detectReader(adapter.config.MimeDetector, dstFile, srcFile); err != nil {
+			return false, err
+		}
+	}
+
+	if !adapter.compressible(mimeType) {
+		if _, err := adapter.adapter.Save(dstFile, srcFile, mimeType); err != nil {
+			return false, err
+		}
+		if adapter.encoder != nil {
+			return true, nil
+		}
+		// 覆盖已压缩的同名文件时移除旧的压缩元数据
+		if err := adapter.encodings.delete(dstFile); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if adapter.encoder != nil {
+		return adapter.saveEncoded(dstFile, srcFile, mimeType)
+	}
+
+	counter := &countingReader{Reader: srcFile}
+	pr, pw := io.Pipe()
+	go func() {
+		writer, err := adapter.newWriter(pw)
+		if err == nil {
+			_, err = io.Copy(writer, counter)
+			if closeErr := writer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	_, err := adapter.adapter.Save(dstFile, pr, mimeType)
+	// 后端提前返回时结束压缩协程
+	_ = pr.Close()
+	if err != nil {
+		return false, err
+	}
+
+	err = adapter.encodings.attach(dstFile, compressionRecord{
+		Encoding: adapter.config.Encoding,
+		Size:     counter.n,
+		MimeType: mimeType,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// saveEncoded 压缩内容暂存到本地临时文件以得到压缩前的大小, 再以Content-Encoding上传
+func (adapter *CompressedAdapter) saveEncoded(dstFile string, srcFile io.Reader, mimeType string) (bool, error) {
+	temp, err := os.CreateTemp(adapter.config.TempDir, "fscmp-*")
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = temp.Close()
+		_ = os.Remove(temp.Name())
+	}()
+
+	writer, err := adapter.newWriter(temp)
+	if err != nil {
+		return false, err
+	}
+	size, err := io.Copy(writer, srcFile)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		_, err = temp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err = adapter.encoder.SaveEncoded(dstFile, temp, mimeType, adapter.config.Encoding, size); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Cover 仅支持未压缩的文件
+func (adapter *CompressedAdapter) Cover(sourceImagePath, coverImagePath string, width, height uint) error {
+	record, err := adapter.record(sourceImagePath)
+	if err != nil {
+		return err
+	}
+	if record != nil {
+		return CompressedCoverUnsupported
+	}
+	return adapter.adapter.Cover(sourceImagePath, coverImagePath, width, height)
+}
+
+// Copy 对象存储的内容编码随对象复制, 其余存储同时复制压缩元数据
+func (adapter *CompressedAdapter) Copy(srcFile, dstFile string) (bool, error) {
+	if _, err := adapter.adapter.Copy(srcFile, dstFile); err != nil {
+		return false, err
+	}
+	if adapter.encoder != nil {
+		return true, nil
+	}
+	if err := adapter.encodings.copy(srcFile, dstFile); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (adapter *CompressedAdapter) Move(dstFile, srcFile string) (bool, error) {
+	if _, err := adapter.Copy(srcFile, dstFile); err != nil {
+		return false, err
+	}
+	return adapter.Delete(srcFile)
+}
+
+func (adapter *CompressedAdapter) Delete(file string) (bool, error) {
+	if adapter.encoder != nil {
+		return adapter.adapter.Delete(file)
+	}
+	if err := adapter.encodings.remove(file); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (adapter *CompressedAdapter) MultipleDelete(fileList []string) (bool, error) {
+	if adapter.encoder != nil {
+		return adapter.adapter.MultipleDelete(fileList)
+	}
+	return adapter.adapter.MultipleDelete(adapter.encodings.withRecords(fileList))
+}
+
+func (adapter *CompressedAdapter) MkDir(dir string, mode os.FileMode) (bool, error) {
+	return adapter.adapter.MkDir(dir, mode)
+}
+
+func (adapter *CompressedAdapter) DeleteDir(dir string) (bool, error) {
+	return adapter.adapter.DeleteDir(dir)
+}
+
+// List 对象存储返回存储的大小; 其余存储隐藏元数据目录, 并返回压缩前的大小
+func (adapter *CompressedAdapter) List(dir string, iterable func(attribute storage.Attribute)) error {
+	if adapter.encoder != nil {
+		return adapter.adapter.List(dir, iterable)
+	}
+	return adapter.encodings.list(dir, iterable, adapter.logical)
+}
+
+// FullPath 对象存储的响应携带Content-Encoding, 其余存储直接访问该地址得到的是压缩后的内容
+func (adapter *CompressedAdapter) FullPath(path string) string {
+	return adapter.adapter.FullPath(path)
+}
+
+func (adapter *CompressedAdapter) OriginalPath(fullPath string) string {
+	return adapter.adapter.OriginalPath(fullPath)
+}
+
+// decompressReader 解压读取器, 关闭时同时关闭解压器与底层读取器
+type decompressReader struct {
+	io.ReadCloser
+	source io.Closer
+}
+
+func (reader *decompressReader) Close() error {
+	err := reader.ReadCloser.Close()
+	if sourceErr := reader.source.Close(); err == nil {
+		err = sourceErr
+	}
+	return err
+}
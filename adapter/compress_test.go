@@ -0,0 +1,46 @@
+package adapter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dysodeng/filesystem/storage"
+)
+
+func TestCompressedAdapterSuffixedUserFile(t *testing.T) {
+	compressed, err := NewCompressedAdapter(NewLocalAdapter(LocalConfig{BasePath: t.TempDir()}), CompressionConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 与元数据同后缀的用户文件不应被隐藏或覆盖元数据
+	files := map[string]string{
+		"notes.txt":                     strings.Repeat("compressible ", 64),
+		"notes.txt" + compressionSuffix: "suffixed",
+	}
+	for file, content := range files {
+		if _, err := compressed.Save(file, bytes.NewReader([]byte(content)), "text/plain"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listed := map[string]int64{}
+	if err := compressed.List("/", func(attribute storage.Attribute) {
+		if !attribute.IsDir() {
+			listed[attribute.Path()] = attribute.(*storage.FileAttribute).FileSize()
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != len(files) {
+		t.Errorf("listed %v, want only %d user files", listed, len(files))
+	}
+	for file, content := range files {
+		if size, ok := listed[file]; !ok || size != int64(len(content)) {
+			t.Errorf("%s: listed size %d, %v, want %d", file, size, ok, len(content))
+		}
+		if got, err := readAll(compressed, file); err != nil || string(got) != content {
+			t.Errorf("read %s: got %q, %v, want %q", file, got, err, content)
+		}
+	}
+}
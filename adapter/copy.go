@@ -0,0 +1,176 @@
+package adapter
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	CrossBucketUnsupported = errors.New("server-side copy between the adapters is not supported")
+	StagingUnsupported     = errors.New("staging bucket is not supported by the adapter")
+)
+
+// BucketCopyAdapter 支持跨存储桶服务端复制的存储适配器
+type BucketCopyAdapter interface {
+	// CopyFrom 将源适配器存储桶中的文件服务端复制到当前存储桶
+	// 源适配器类型不同, 或账号、区域不满足服务端复制条件时返回 CrossBucketUnsupported
+	// @param src Adapter 源适配器
+	// @param srcFile string 源文件路径
+	// @param dstFile string 目标文件路径
+	CopyFrom(src Adapter, srcFile, dstFile string) error
+}
+
+// StagingAdapter 配置了暂存桶(StayBucketName)的存储适配器
+// 文件先上传到暂存桶, 审核通过后再发布到正式存储桶
+type StagingAdapter interface {
+	// StayBucket 返回访问暂存桶的适配器, 与当前适配器共用凭证与配置
+	StayBucket() (Adapter, error)
+
+	// Promote 将暂存桶中的文件服务端复制到当前存储桶
+	// @param srcFile string 暂存桶文件路径
+	// @param dstFile string 目标文件路径
+	Promote(srcFile, dstFile string) error
+}
+
+// CopyAcross 在两个适配器之间复制文件
+// 同一适配器时调用 Copy; 同一云存储账号的不同存储桶使用服务端复制; 其余情况读取源文件流式写入目标
+// @param src Adapter 源适配器
+// @param srcFile string 源文件路径
+// @param dst Adapter 目标适配器
+// @param dstFile string 目标文件路径
+func CopyAcross(src Adapter, srcFile string, dst Adapter, dstFile string) (bool, error) {
+	if src == dst {
+		return src.Copy(srcFile, dstFile)
+	}
+
+	// 源适配器只用于判断服务端复制条件及取得凭证, 传入底层适配器; 目标端经重试、观测装饰器发起复制
+	if copier, bareSrc := bucketCopier(dst), bareAdapter(src); copier != nil && bareSrc != nil {
+		err := copier.CopyFrom(bareSrc, srcFile, dstFile)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, CrossBucketUnsupported) {
+			return false, err
+		}
+	}
+
+	return streamCopy(src, srcFile, dst, dstFile)
+}
+
+// bucketCopier 底层适配器支持跨存储桶服务端复制时返回装饰器链最外层的实现, 重试、观测装饰器转发至底层适配器;
+// 遇到其他装饰器时返回nil
+func bucketCopier(adapter Adapter) BucketCopyAdapter {
+	if _, ok := bareAdapter(adapter).(BucketCopyAdapter); !ok {
+		return nil
+	}
+	copier, _ := adapter.(BucketCopyAdapter)
+	return copier
+}
+
+// Promote 将暂存桶中的文件发布到正式存储桶, 沿装饰器链查找StagingAdapter
+// @param adapter Adapter 存储适配器
+// @param srcFile string 暂存桶文件路径
+// @param dstFile string 目标文件路径
+func Promote(adapter Adapter, srcFile, dstFile string) error {
+	stagingAdapter, ok := As[StagingAdapter](adapter)
+	if !ok {
+		return StagingUnsupported
+	}
+	return stagingAdapter.Promote(srcFile, dstFile)
+}
+
+// bareAdapter 跳过重试、观测装饰器返回底层适配器
+// 遇到改写内容或维护元数据的装饰器时返回nil, 此时不能绕过装饰器直接操作存储
+func bareAdapter(adapter Adapter) Adapter {
+	for adapter != nil {
+		switch wrapper := adapter.(type) {
+		case *RetryAdapter:
+			adapter = wrapper.Unwrap()
+		case *InstrumentedAdapter:
+			adapter = wrapper.Unwrap()
+		case Wrapper:
+			return nil
+		default:
+			return adapter
+		}
+	}
+	return nil
+}
+
+// 服务端单次复制上限, OSS为1GB, COS、OBS、MinIO为5GB, 超过上限的文件使用分片复制
+const (
+	maxSingleCopySize    = 5 << 30
+	ossMaxSingleCopySize = 1 << 30
+	copyPartSize         = 128 << 20
+	maxCopyParts         = 10000
+)
+
+// copyTooLarge 单次复制失败是否可能因源文件超过上限
+// 各服务返回的错误码不同(EntityTooLarge、InvalidRequest、InvalidArgument), 需查询源文件大小确认
+func copyTooLarge(err error) bool {
+	_, code := serviceErrorStatus(err)
+	switch code {
+	case "EntityTooLarge", "InvalidRequest", "InvalidArgument":
+		return true
+	}
+	return false
+}
+
+// copyWithFallback 服务端复制, 不预先查询源文件大小
+// 先尝试单次复制, 失败且源文件确实超过单次复制上限时改用分片复制, 否则返回单次复制的错误
+// @param limit int64 单次复制上限
+// @param single func() error 单次复制
+// @param size func() (int64, error) 查询源文件大小
+// @param multipart func(size int64) error 分片复制
+func copyWithFallback(limit int64, single func() error, size func() (int64, error), multipart func(size int64) error) error {
+	err := single()
+	if !copyTooLarge(err) {
+		return notExists(err)
+	}
+	srcSize, sizeErr := size()
+	if sizeErr != nil {
+		return notExists(sizeErr)
+	}
+	if srcSize <= limit {
+		return err
+	}
+	return multipart(srcSize)
+}
+
+// copyPart 分片复制的分片, 字节范围为闭区间
+type copyPart struct {
+	number int
+	start  int64
+	end    int64
+}
+
+// copyParts 按分片大小切分文件, 分片数超过上限时增大分片
+func copyParts(size int64) []copyPart {
+	partSize := int64(copyPartSize)
+	if size > partSize*maxCopyParts {
+		partSize = (size + maxCopyParts - 1) / maxCopyParts
+	}
+	parts := make([]copyPart, 0, (size+partSize-1)/partSize)
+	for start := int64(0); start < size; start += partSize {
+		parts = append(parts, copyPart{
+			number: len(parts) + 1,
+			start:  start,
+			end:    min(start+partSize, size) - 1,
+		})
+	}
+	return parts
+}
+
+// copyPartsConcurrently 并发复制全部分片, 返回按分片序号排列的ETag
+func copyPartsConcurrently(parts []copyPart, uploadPart func(part copyPart) (string, error)) ([]string, error) {
+	etags := make([]string, len(parts))
+	errs := make([]error, len(parts))
+	RunBatch(len(parts), BatchOptions{}, func(i int) {
+		etags[i], errs[i] = uploadPart(parts[i])
+	})
+	for i, err := range errs {
+		if err != nil {
+			return nil, errors.Wrapf(err, "copy part %d", parts[i].number)
+		}
+	}
+	return etags, nil
+}
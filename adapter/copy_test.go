@@ -0,0 +1,99 @@
+package adapter
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/huaweicloud/huaweicloud-sdk-go-obs/obs"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"github.com/tencentyun/cos-go-sdk-v5"
+)
+
+// bucketCopyStub 服务端复制先返回指定次数的临时性错误
+type bucketCopyStub struct {
+	Adapter
+	failures int
+	calls    int
+}
+
+func (stub *bucketCopyStub) CopyFrom(src Adapter, srcFile, dstFile string) error {
+	stub.calls++
+	if stub.calls <= stub.failures {
+		return syscall.ECONNRESET
+	}
+	return nil
+}
+
+func TestCopyAcrossServerSideThroughDecorators(t *testing.T) {
+	observer := &recordingObserver{}
+	stub := &bucketCopyStub{failures: 1}
+	dst := NewInstrumentedAdapter(NewRetryAdapter(stub, RetryConfig{BaseDelay: time.Millisecond}), "stub", observer)
+	src := NewLocalAdapter(LocalConfig{BasePath: t.TempDir()})
+
+	if _, err := CopyAcross(src, "a.txt", dst, "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if stub.calls != 2 {
+		t.Errorf("CopyFrom called %d times, want a retry after the first failure", stub.calls)
+	}
+	if len(observer.spans) != 1 || observer.spans[0] != (recordedSpan{operation: OperationCopy, path: "a.txt"}) {
+		t.Errorf("got spans %+v, want one successful copy", observer.spans)
+	}
+}
+
+func TestCopyWithFallback(t *testing.T) {
+	const limit = 100
+	tests := []struct {
+		name          string
+		singleErr     error
+		size          int64
+		wantSize      bool  // 是否查询源文件大小
+		wantMultipart bool  // 是否改用分片复制
+		wantErr       error // 为nil时表示成功
+	}{
+		{name: "single copy succeeds"},
+		{name: "oss over limit", singleErr: oss.ServiceError{StatusCode: 400, Code: "InvalidArgument"}, size: limit + 1, wantSize: true, wantMultipart: true},
+		{name: "cos over limit", singleErr: &cos.ErrorResponse{Code: "EntityTooLarge"}, size: limit + 1, wantSize: true, wantMultipart: true},
+		{name: "obs over limit", singleErr: obs.ObsError{Code: "EntityTooLarge"}, size: limit + 1, wantSize: true, wantMultipart: true},
+		{name: "minio over limit", singleErr: minio.ErrorResponse{StatusCode: 400, Code: "InvalidRequest"}, size: limit + 1, wantSize: true, wantMultipart: true},
+		{name: "invalid request within limit", singleErr: minio.ErrorResponse{StatusCode: 400, Code: "InvalidRequest"}, size: limit, wantSize: true, wantErr: minio.ErrorResponse{StatusCode: 400, Code: "InvalidRequest"}},
+		{name: "missing source", singleErr: oss.ServiceError{StatusCode: 404, Code: "NoSuchKey"}, wantErr: FileNotExists},
+		{name: "access denied", singleErr: minio.ErrorResponse{StatusCode: 403, Code: "AccessDenied"}, wantErr: minio.ErrorResponse{StatusCode: 403, Code: "AccessDenied"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sized, multipart bool
+			err := copyWithFallback(limit, func() error {
+				return test.singleErr
+			}, func() (int64, error) {
+				sized = true
+				return test.size, nil
+			}, func(size int64) error {
+				multipart = true
+				if size != test.size {
+					t.Errorf("multipart copy got size %d, want %d", size, test.size)
+				}
+				return nil
+			})
+
+			if sized != test.wantSize || multipart != test.wantMultipart {
+				t.Errorf("queried size %v, multipart %v; want %v, %v", sized, multipart, test.wantSize, test.wantMultipart)
+			}
+			switch {
+			case test.wantErr == nil:
+				if err != nil {
+					t.Errorf("got %v, want success", err)
+				}
+			case errors.Is(test.wantErr, FileNotExists):
+				if !errors.Is(err, FileNotExists) {
+					t.Errorf("got %v, want FileNotExists", err)
+				}
+			case err == nil || err.Error() != test.wantErr.Error():
+				t.Errorf("got %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
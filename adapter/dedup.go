@@ -0,0 +1,464 @@
+package adapter
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultBlobPrefix = ".blobs/"
+	dedupRefSuffix    = ".fsdedup"
+	blobRecordSuffix  = ".fsref"
+)
+
+// DedupAdapter 内容寻址去重装饰器
+// Save 时计算内容的SHA-256, 相同内容只在 <前缀><aa>/<bb>/<sha256> 保存一份,
+// 逻辑路径只在 .sidecars/<文件路径>.fsdedup 保存引用记录, 内容的引用计数保存在 .sidecars/<内容路径>.fsref 中。
+// Copy 与 Move 只复制、移动引用记录; Delete 删除引用记录, 引用计数归零时删除内容。
+// 没有引用记录的文件按原样读写。引用计数在进程内串行更新, 多个进程共用同一存储时需自行加锁。
+type DedupAdapter struct {
+	adapter Adapter
+	config  DedupConfig
+	refs    sidecar
+	records sidecar
+	mu      sync.Mutex
+}
+
+type DedupConfig struct {
+	BlobPrefix   string       // 内容存储路径前缀, 默认 .blobs/
+	TempDir      string       // 计算摘要时暂存上传内容的本地目录, 默认系统临时目录
+	MimeDetector MimeDetector // 文件类型识别, 默认沿用被装饰适配器的配置
+}
+
+// dedupRef 逻辑路径的引用记录
+type dedupRef struct {
+	SHA256   string `json:"sha256"` // 十六进制
+	Size     int64  `json:"size"`
+	MimeType string `json:"mime_type"`
+}
+
+// blobRecord 内容的引用计数
+type blobRecord struct {
+	Refs int   `json:"refs"`
+	Size int64 `json:"size"`
+}
+
+func NewDedupAdapter(adapter Adapter, config DedupConfig) (Adapter, error) {
+	if config.BlobPrefix == "" {
+		config.BlobPrefix = defaultBlobPrefix
+	}
+	config.BlobPrefix = strings.Trim(config.BlobPrefix, "/")
+	if config.BlobPrefix == "" {
+		return nil, &ConfigError{Driver: "dedup", Field: "BlobPrefix", Reason: "must not be the root directory"}
+	}
+	config.BlobPrefix += "/"
+	if config.MimeDetector == nil {
+		config.MimeDetector = resolveMimeDetector(adapter)
+	}
+	return &DedupAdapter{
+		adapter: adapter,
+		config:  config,
+		refs:    sidecar{adapter: adapter, suffix: dedupRefSuffix, dir: sidecarsDir},
+		records: sidecar{adapter: adapter, suffix: blobRecordSuffix, dir: sidecarsDir},
+	}, nil
+}
+
+// Unwrap 返回被装饰的适配器
+func (adapter *DedupAdapter) Unwrap() Adapter {
+	return adapter.adapter
+}
+
+// inBlobs 路径是否位于内容存储目录内
+func (adapter *DedupAdapter) inBlobs(file string) bool {
+	file = strings.TrimLeft(file, "/")
+	return file == strings.TrimSuffix(adapter.config.BlobPrefix, "/") || strings.HasPrefix(file, adapter.config.BlobPrefix)
+}
+
+// blobPath 内容存储路径
+func (adapter *DedupAdapter) blobPath(sum string) string {
+	return adapter.config.BlobPrefix + sum[:2] + "/" + sum[2:4] + "/" + sum
+}
+
+// ref 读取引用记录, 没有引用记录时返回nil
+func (adapter *DedupAdapter) ref(file string) (*dedupRef, error) {
+	var ref dedupRef
+	if err := adapter.refs.load(file, &ref); err != nil {
+		if errors.Is(err, FileNotExists) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ref, nil
+}
+
+// refsDir 目录下文件的引用记录所在目录
+func (adapter *DedupAdapter) refsDir(dir string) string {
+	return path.Join(sidecarsDir, dir)
+}
+
+// logical 由引用记录文件的属性生成逻辑文件属性
+func (adapter *DedupAdapter) logical(attribute storage.Attribute) (storage.Attribute, error) {
+	file := adapter.refs.file(attribute.Path())
+	ref, err := adapter.ref(file)
+	if err != nil {
+		return nil, err
+	}
+	if ref == nil {
+		return nil, FileNotExists
+	}
+	sum, err := hex.DecodeString(ref.SHA256)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid dedup ref %s", file)
+	}
+	name := strings.TrimSuffix(attribute.Name(), dedupRefSuffix)
+	return storage.NewFileAttribute(name, file, attribute.Visibility(), ref.MimeType, ref.Size, attribute.LastModified()).
+		WithChecksums("", "", base64.StdEncoding.EncodeToString(sum)), nil
+}
+
+// spool 将内容暂存到本地临时文件并计算SHA-256, 调用方负责删除临时文件
+func (adapter *DedupAdapter) spool(src io.Reader) (*os.File, string, int64, error) {
+	temp, err := os.CreateTemp(adapter.config.TempDir, "fsdedup-*")
+	if err != nil {
+		return nil, "", 0, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(temp, hash), src)
+	if err == nil {
+		_, err = temp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = temp.Close()
+		_ = os.Remove(temp.Name())
+		return nil, "", 0, err
+	}
+	return temp, hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// retain 增加内容引用计数, 调用方需持有锁
+func (adapter *DedupAdapter) retain(sum string, size int64) error {
+	var record blobRecord
+	if err := adapter.records.load(adapter.blobPath(sum), &record); err != nil && !errors.Is(err, FileNotExists) {
+		return err
+	}
+	record.Refs++
+	record.Size = size
+	return adapter.records.save(adapter.blobPath(sum), record)
+}
+
+// release 减少内容引用计数, 归零时删除内容, 调用方需持有锁
+func (adapter *DedupAdapter) release(sum string) error {
+	blob := adapter.blobPath(sum)
+	var record blobRecord
+	if err := adapter.records.load(blob, &record); err != nil {
+		if errors.Is(err, FileNotExists) {
+			return errors.Errorf("dedup blob %s has no reference record", sum)
+		}
+		return err
+	}
+	if record.Refs <= 0 {
+		return errors.Errorf("dedup blob %s has %d references", sum, record.Refs)
+	}
+	record.Refs--
+	if record.Refs > 0 {
+		return adapter.records.save(blob, record)
+	}
+	if adapter.adapter.HasFile(blob) {
+		if _, err := adapter.adapter.Delete(blob); err != nil {
+			return err
+		}
+	}
+	return adapter.records.delete(blob)
+}
+
+// upload 上传内容到内容存储路径
+func (adapter *DedupAdapter) upload(blob string, content io.Reader, mimeType string) error {
+	if _, err := adapter.adapter.MkDir(path.Dir(blob), 0755); err != nil {
+		return err
+	}
+	_, err := adapter.adapter.Save(blob, content, mimeType)
+	return err
+}
+
+// ensureBlob 确认内容仍然存在, 调用方需持有锁
+// 内容在锁外检查或上传, 期间可能被并发的 Delete 释放删除, 此时重新上传 content, 没有 content 时返回 FileNotExists
+func (adapter *DedupAdapter) ensureBlob(ref dedupRef, content io.ReadSeeker) error {
+	blob := adapter.blobPath(ref.SHA256)
+	if adapter.adapter.HasFile(blob) {
+		return nil
+	}
+	if content == nil {
+		return FileNotExists
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return adapter.upload(blob, content, ref.MimeType)
+}
+
+// link 为逻辑路径写入引用记录并增加引用计数, 覆盖已有文件时释放其原内容
+// @param content io.ReadSeeker 内容已被删除时重新上传的内容, 为nil时返回 FileNotExists
+func (adapter *DedupAdapter) link(file string, ref dedupRef, content io.ReadSeeker) error {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+
+	if err := adapter.ensureBlob(ref, content); err != nil {
+		return err
+	}
+	old, err := adapter.ref(file)
+	if err != nil {
+		return err
+	}
+	if err = adapter.retain(ref.SHA256, ref.Size); err != nil {
+		return err
+	}
+	if err = adapter.refs.save(file, ref); err != nil {
+		_ = adapter.release(ref.SHA256)
+		return err
+	}
+	if old != nil {
+		return adapter.release(old.SHA256)
+	}
+	// 覆盖未去重的同名文件时删除原文件, 避免与引用记录并存
+	if adapter.adapter.HasFile(file) {
+		_, err = adapter.adapter.Delete(file)
+	}
+	return err
+}
+
+// unlink 删除引用记录并释放内容
+func (adapter *DedupAdapter) unlink(file string, ref dedupRef) error {
+	adapter.mu.Lock()
+	defer adapter.mu.Unlock()
+
+	if err := adapter.refs.delete(file); err != nil {
+		return err
+	}
+	return adapter.release(ref.SHA256)
+}
+
+func (adapter *DedupAdapter) Info(file string) (storage.Attribute, error) {
+	if !adapter.adapter.HasFile(adapter.refs.path(file)) {
+		return adapter.adapter.Info(file)
+	}
+	attribute, err := adapter.adapter.Info(adapter.refs.path(file))
+	if err != nil {
+		return nil, err
+	}
+	return adapter.logical(attribute)
+}
+
+func (adapter *DedupAdapter) HasFile(file string) bool {
+	return adapter.adapter.HasFile(adapter.refs.path(file)) || adapter.adapter.HasFile(file)
+}
+
+// HasDir 只有去重文件的目录仅存在于引用记录目录中
+func (adapter *DedupAdapter) HasDir(file string) bool {
+	return adapter.adapter.HasDir(file) || adapter.adapter.HasDir(adapter.refsDir(file))
+}
+
+func (adapter *DedupAdapter) Read(file string) (io.ReadCloser, error) {
+	ref, err := adapter.ref(file)
+	if err != nil {
+		return nil, err
+	}
+	if ref == nil {
+		return adapter.adapter.Read(file)
+	}
+	return adapter.adapter.Read(adapter.blobPath(ref.SHA256))
+}
+
+// Save 暂存内容并计算SHA-256, 存储中已有相同内容时只写入引用记录
+func (adapter *DedupAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (bool, error) {
+	if mimeType == "" {
+		var err error
+		if mimeType, srcFile, err = detectReader(adapter.config.MimeDetector, dstFile, srcFile); err != nil {
+			return false, err
+		}
+	}
+
+	temp, sum, size, err := adapter.spool(srcFile)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = temp.Close()
+		_ = os.Remove(temp.Name())
+	}()
+
+	// 不持锁上传, 避免大文件上传阻塞其他文件的引用计数更新; link 在锁内再次确认内容存在
+	if blob := adapter.blobPath(sum); !adapter.adapter.HasFile(blob) {
+		if err = adapter.upload(blob, temp, mimeType); err != nil {
+			return false, err
+		}
+	}
+
+	if err = adapter.link(dstFile, dedupRef{SHA256: sum, Size: size, MimeType: mimeType}, temp); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (adapter *DedupAdapter) Cover(sourceImagePath, coverImagePath string, width, height uint) error {
+	ref, err := adapter.ref(sourceImagePath)
+	if err != nil {
+		return err
+	}
+	if ref != nil {
+		sourceImagePath = adapter.blobPath(ref.SHA256)
+	}
+	return adapter.adapter.Cover(sourceImagePath, coverImagePath, width, height)
+}
+
+// Copy 去重文件只复制引用记录并增加引用计数
+func (adapter *DedupAdapter) Copy(srcFile, dstFile string) (bool, error) {
+	ref, err := adapter.ref(srcFile)
+	if err != nil {
+		return false, err
+	}
+	if ref == nil {
+		return adapter.adapter.Copy(srcFile, dstFile)
+	}
+	if err = adapter.link(dstFile, *ref, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Move 去重文件只移动引用记录
+func (adapter *DedupAdapter) Move(dstFile, srcFile string) (bool, error) {
+	ref, err := adapter.ref(srcFile)
+	if err != nil {
+		return false, err
+	}
+	if ref == nil {
+		return adapter.adapter.Move(dstFile, srcFile)
+	}
+	if strings.TrimLeft(srcFile, "/") == strings.TrimLeft(dstFile, "/") {
+		return true, nil
+	}
+	if err = adapter.link(dstFile, *ref, nil); err != nil {
+		return false, err
+	}
+	if err = adapter.unlink(srcFile, *ref); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete 去重文件删除引用记录, 内容引用计数归零时删除内容
+func (adapter *DedupAdapter) Delete(file string) (bool, error) {
+	ref, err := adapter.ref(file)
+	if err != nil {
+		return false, err
+	}
+	if ref == nil {
+		return adapter.adapter.Delete(file)
+	}
+	if err = adapter.unlink(file, *ref); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (adapter *DedupAdapter) MultipleDelete(fileList []string) (bool, error) {
+	for _, file := range fileList {
+		if _, err := adapter.Delete(file); err != nil {
+			return false, errors.Wrap(err, file)
+		}
+	}
+	return true, nil
+}
+
+func (adapter *DedupAdapter) MkDir(dir string, mode os.FileMode) (bool, error) {
+	return adapter.adapter.MkDir(dir, mode)
+}
+
+// DeleteDir 目录下仍有去重文件时返回 DirectoryNotEmpty, 避免引用记录脱离目录残留
+// 只存在于引用记录目录中的目录删除引用记录目录
+func (adapter *DedupAdapter) DeleteDir(dir string) (bool, error) {
+	if refsDir := adapter.refsDir(dir); adapter.adapter.HasDir(refsDir) {
+		var found bool
+		err := adapter.adapter.List(refsDir, func(attribute storage.Attribute) {
+			found = found || !attribute.IsDir() && !sidecarHidden(refsDir, attribute.Path()) && adapter.refs.is(attribute.Path())
+		})
+		if err != nil {
+			return false, err
+		}
+		if found {
+			return false, DirectoryNotEmpty
+		}
+		if !adapter.adapter.HasDir(dir) {
+			return adapter.adapter.DeleteDir(refsDir)
+		}
+	}
+	return adapter.adapter.DeleteDir(dir)
+}
+
+// List 隐藏内容存储目录与元数据目录, 并将引用记录目录中的引用记录以逻辑文件返回
+// 只有去重文件的目录可能只存在于引用记录目录中, 同样以目录返回
+func (adapter *DedupAdapter) List(dir string, iterable func(attribute storage.Attribute)) error {
+	refsDir := adapter.refsDir(dir)
+	hasRefs := adapter.adapter.HasDir(refsDir)
+
+	listed := make(map[string]bool)
+	err := adapter.adapter.List(dir, func(attribute storage.Attribute) {
+		if adapter.inBlobs(attribute.Path()) || sidecarHidden(dir, attribute.Path()) {
+			return
+		}
+		if attribute.IsDir() {
+			listed[strings.Trim(attribute.Path(), "/")] = true
+		}
+		iterable(attribute)
+	})
+	if err != nil && !(hasRefs && errors.Is(err, FileNotExists)) {
+		return err
+	}
+	if !hasRefs {
+		return nil
+	}
+
+	var listErr error
+	err = adapter.adapter.List(refsDir, func(attribute storage.Attribute) {
+		if listErr != nil || sidecarHidden(refsDir, attribute.Path()) {
+			return
+		}
+		file := adapter.refs.file(attribute.Path())
+		switch {
+		case adapter.inBlobs(file):
+		case attribute.IsDir():
+			if !listed[strings.Trim(file, "/")] {
+				listed[strings.Trim(file, "/")] = true
+				iterable(storage.NewDirectoryAttribute(attribute.Name(), file, attribute.Visibility(), attribute.LastModified()))
+			}
+		case adapter.refs.is(attribute.Path()):
+			if attribute, listErr = adapter.logical(attribute); listErr == nil {
+				iterable(attribute)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return listErr
+}
+
+// FullPath 去重文件返回内容存储路径的地址
+func (adapter *DedupAdapter) FullPath(path string) string {
+	if ref, err := adapter.ref(path); err == nil && ref != nil {
+		path = adapter.blobPath(ref.SHA256)
+	}
+	return adapter.adapter.FullPath(path)
+}
+
+func (adapter *DedupAdapter) OriginalPath(fullPath string) string {
+	return adapter.adapter.OriginalPath(fullPath)
+}
@@ -0,0 +1,151 @@
+package adapter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+func newTestDedupAdapter(t *testing.T) *DedupAdapter {
+	t.Helper()
+	dedup, err := NewDedupAdapter(NewLocalAdapter(LocalConfig{BasePath: t.TempDir()}), DedupConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dedup.(*DedupAdapter)
+}
+
+func TestDedupAdapterListsLogicalFiles(t *testing.T) {
+	dedup := newTestDedupAdapter(t)
+	// 与引用记录同后缀的用户文件不应被当作引用记录; dir 未创建, 只存在于引用记录目录中
+	files := map[string]string{
+		"a.txt":                  "shared",
+		"dir/b.txt":              "shared",
+		"c.txt" + dedupRefSuffix: "suffixed",
+	}
+	for file, content := range files {
+		if _, err := dedup.Save(file, bytes.NewReader([]byte(content)), "text/plain"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listed := map[string]bool{}
+	if err := dedup.List("/", func(attribute storage.Attribute) {
+		if attribute.IsDir() {
+			listed[attribute.Path()+"/"] = true
+		} else {
+			listed[attribute.Path()] = true
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/", "a.txt", "dir/", "dir/b.txt", "c.txt" + dedupRefSuffix}
+	if len(listed) != len(want) {
+		t.Errorf("listed %v, want %v", listed, want)
+	}
+	for _, path := range want {
+		if !listed[path] {
+			t.Errorf("%s was not listed", path)
+		}
+	}
+	for file, content := range files {
+		if got, err := readAll(dedup, file); err != nil || string(got) != content {
+			t.Errorf("read %s: got %q, %v, want %q", file, got, err, content)
+		}
+	}
+
+	if !dedup.HasDir("dir") {
+		t.Fatal("dir holding only deduplicated files does not exist")
+	}
+	if _, err := dedup.DeleteDir("dir"); !errors.Is(err, DirectoryNotEmpty) {
+		t.Fatalf("delete non-empty dir: got %v, want DirectoryNotEmpty", err)
+	}
+	if _, err := dedup.Delete("dir/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dedup.DeleteDir("dir"); err != nil {
+		t.Fatal(err)
+	}
+	if dedup.HasDir("dir") {
+		t.Error("dir still exists after DeleteDir")
+	}
+}
+
+func TestDedupAdapterConcurrentRefCount(t *testing.T) {
+	dedup := newTestDedupAdapter(t)
+	content := []byte("shared content")
+	digest := sha256.Sum256(content)
+	sum := hex.EncodeToString(digest[:])
+	refs := func() int {
+		var record blobRecord
+		if err := dedup.records.load(dedup.blobPath(sum), &record); err != nil {
+			if errors.Is(err, FileNotExists) {
+				return 0
+			}
+			t.Fatal(err)
+		}
+		return record.Refs
+	}
+	parallel := func(n int, fn func(i int) error) {
+		t.Helper()
+		var wg sync.WaitGroup
+		errs := make([]error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = fn(i)
+			}(i)
+		}
+		wg.Wait()
+		for i, err := range errs {
+			if err != nil {
+				t.Fatalf("task %d: %v", i, err)
+			}
+		}
+	}
+	const n = 16
+
+	parallel(n, func(i int) error {
+		_, err := dedup.Save("f"+strconv.Itoa(i)+".txt", bytes.NewReader(content), "text/plain")
+		return err
+	})
+	if got := refs(); got != n {
+		t.Fatalf("after saves: %d references, want %d", got, n)
+	}
+
+	// 一半删除、一半复制, 引用数保持不变
+	parallel(n, func(i int) error {
+		file := "f" + strconv.Itoa(i) + ".txt"
+		if i%2 == 0 {
+			_, err := dedup.Delete(file)
+			return err
+		}
+		_, err := dedup.Copy(file, "copy"+strconv.Itoa(i)+".txt")
+		return err
+	})
+	if got := refs(); got != n {
+		t.Fatalf("after deletes and copies: %d references, want %d", got, n)
+	}
+
+	parallel(n, func(i int) error {
+		file := "copy" + strconv.Itoa(i) + ".txt"
+		if i%2 == 0 {
+			file = "f" + strconv.Itoa(i+1) + ".txt"
+		}
+		_, err := dedup.Delete(file)
+		return err
+	})
+	if got := refs(); got != 0 {
+		t.Errorf("after deleting every reference: %d references remain", got)
+	}
+	if dedup.adapter.HasFile(dedup.blobPath(sum)) {
+		t.Error("blob still exists after its last reference was deleted")
+	}
+}
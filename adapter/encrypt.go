@@ -0,0 +1,552 @@
+package adapter
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+var (
+	KeyNotFound               = errors.New("encryption key does not exists")
+	EnvelopeNotFound          = errors.New("encryption envelope does not exists")
+	EnvelopeInvalid           = errors.New("encryption envelope is invalid")
+	DecryptFailed             = errors.New("file decrypt failed")
+	EncryptedCoverUnsupported = errors.New("cover is not supported for encrypted files")
+)
+
+const (
+	encryptionAlgorithm        = "AES-256-GCM"
+	encryptionVersion          = 1
+	encryptionSuffix           = ".fsenc"
+	encryptionKeyMetadata      = "encryption-key-id"
+	defaultEncryptionChunkSize = 64 * 1024
+	maxEncryptionChunkSize     = 16 << 20
+	noncePrefixSize            = 7
+	dataKeySize                = 32
+)
+
+// KeyProvider 主密钥提供者, 负责数据密钥的加密(wrap)与解密(unwrap)
+type KeyProvider interface {
+	// CurrentKeyID 当前主密钥ID
+	CurrentKeyID() string
+
+	// WrapKey 使用当前主密钥加密数据密钥
+	// @param dataKey []byte 数据密钥
+	WrapKey(dataKey []byte) (keyID string, wrappedKey []byte, err error)
+
+	// UnwrapKey 使用指定主密钥解密数据密钥
+	// @param keyID string 主密钥ID
+	// @param wrappedKey []byte 加密后的数据密钥
+	UnwrapKey(keyID string, wrappedKey []byte) ([]byte, error)
+}
+
+// StaticKeyProvider 静态主密钥提供者, 主密钥为32字节AES-256密钥
+type StaticKeyProvider struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewStaticKeyProvider 创建静态主密钥提供者
+// @param currentKeyID string 当前主密钥ID, 新文件及Rewrap使用该密钥
+// @param keys map[string][]byte 主密钥, 保留旧密钥以便解密及轮换
+func NewStaticKeyProvider(currentKeyID string, keys map[string][]byte) (*StaticKeyProvider, error) {
+	if _, ok := keys[currentKeyID]; !ok {
+		return nil, errors.Wrap(KeyNotFound, currentKeyID)
+	}
+
+	provider := &StaticKeyProvider{
+		current: currentKeyID,
+		keys:    make(map[string]cipher.AEAD, len(keys)),
+	}
+	for keyID, key := range keys {
+		if len(key) != dataKeySize {
+			return nil, errors.Errorf("encryption key %q must be %d bytes", keyID, dataKeySize)
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		provider.keys[keyID] = aead
+	}
+
+	return provider, nil
+}
+
+func (provider *StaticKeyProvider) CurrentKeyID() string {
+	return provider.current
+}
+
+func (provider *StaticKeyProvider) WrapKey(dataKey []byte) (string, []byte, error) {
+	aead := provider.keys[provider.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return provider.current, aead.Seal(nonce, nonce, dataKey, []byte(provider.current)), nil
+}
+
+func (provider *StaticKeyProvider) UnwrapKey(keyID string, wrappedKey []byte) ([]byte, error) {
+	aead, ok := provider.keys[keyID]
+	if !ok {
+		return nil, errors.Wrap(KeyNotFound, keyID)
+	}
+	if len(wrappedKey) < aead.NonceSize() {
+		return nil, DecryptFailed
+	}
+	nonce, sealed := wrappedKey[:aead.NonceSize()], wrappedKey[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, errors.Wrap(DecryptFailed, err.Error())
+	}
+	return dataKey, nil
+}
+
+// EncryptedAdapter 客户端加密装饰器
+// Save 时以AES-256-GCM分块流式加密, Read 时解密; 每个文件使用独立的数据密钥,
+// 数据密钥经KeyProvider加密后与主密钥ID一同保存在 .sidecars/<文件路径>.fsenc 元数据文件中,
+// 主密钥ID同时写入文件的自定义元数据 encryption-key-id, 底层适配器不支持自定义元数据时只保存在元数据文件中;
+// 轮换主密钥时只需Rewrap元数据, 无需重新上传数据。
+// 没有元数据文件的文件无法确认是否为密文, Read 返回 EnvelopeNotFound, 不按明文返回。
+type EncryptedAdapter struct {
+	adapter      Adapter
+	keys         KeyProvider
+	chunkSize    int
+	mimeDetector MimeDetector
+	envelopes    sidecar
+}
+
+type EncryptionConfig struct {
+	KeyProvider  KeyProvider
+	ChunkSize    int          // 明文分块大小, 默认64KB, 最大16MB
+	MimeDetector MimeDetector // 文件类型识别, 默认沿用被装饰适配器的配置
+}
+
+// encryptionEnvelope 加密元数据
+type encryptionEnvelope struct {
+	Version     int    `json:"version"`
+	Algorithm   string `json:"algorithm"`
+	KeyID       string `json:"key_id"`
+	WrappedKey  []byte `json:"wrapped_key"`
+	NoncePrefix []byte `json:"nonce_prefix"`
+	ChunkSize   int    `json:"chunk_size"`
+	Size        int64  `json:"size"`
+	MimeType    string `json:"mime_type"`
+}
+
+func NewEncryptedAdapter(adapter Adapter, config EncryptionConfig) (Adapter, error) {
+	if config.KeyProvider == nil {
+		return nil, &ConfigError{Driver: "encryption", Field: "KeyProvider", Reason: "is required"}
+	}
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = defaultEncryptionChunkSize
+	}
+	if config.ChunkSize > maxEncryptionChunkSize {
+		return nil, &ConfigError{Driver: "encryption", Field: "ChunkSize", Reason: "must not exceed 16MB"}
+	}
+	if config.MimeDetector == nil {
+		config.MimeDetector = resolveMimeDetector(adapter)
+	}
+	return &EncryptedAdapter{
+		adapter:      adapter,
+		keys:         config.KeyProvider,
+		chunkSize:    config.ChunkSize,
+		mimeDetector: config.MimeDetector,
+		envelopes:    sidecar{adapter: adapter, suffix: encryptionSuffix, dir: sidecarsDir},
+	}, nil
+}
+
+// Unwrap 返回被装饰的适配器
+func (adapter *EncryptedAdapter) Unwrap() Adapter {
+	return adapter.adapter
+}
+
+// envelope 读取加密元数据, 元数据文件不存在时返回 EnvelopeNotFound
+func (adapter *EncryptedAdapter) envelope(file string) (*encryptionEnvelope, error) {
+	var envelope encryptionEnvelope
+	if err := adapter.envelopes.load(file, &envelope); err != nil {
+		if errors.Is(err, FileNotExists) {
+			return nil, errors.Wrap(EnvelopeNotFound, file)
+		}
+		return nil, err
+	}
+	if err := envelope.validate(); err != nil {
+		return nil, errors.Wrap(err, file)
+	}
+	return &envelope, nil
+}
+
+// validate 校验元数据文件中决定解密参数的字段, 元数据文件损坏或被篡改时返回 EnvelopeInvalid
+func (envelope *encryptionEnvelope) validate() error {
+	switch {
+	case envelope.Version != encryptionVersion || envelope.Algorithm != encryptionAlgorithm:
+		return errors.Wrapf(EnvelopeInvalid, "unsupported version %d algorithm %q", envelope.Version, envelope.Algorithm)
+	case envelope.ChunkSize <= 0 || envelope.ChunkSize > maxEncryptionChunkSize:
+		return errors.Wrapf(EnvelopeInvalid, "chunk size %d", envelope.ChunkSize)
+	case len(envelope.NoncePrefix) != noncePrefixSize:
+		return errors.Wrapf(EnvelopeInvalid, "nonce prefix of %d bytes", len(envelope.NoncePrefix))
+	case envelope.Size < 0:
+		return errors.Wrapf(EnvelopeInvalid, "size %d", envelope.Size)
+	}
+	return nil
+}
+
+// logical 将属性中的大小与类型还原为明文信息
+func (adapter *EncryptedAdapter) logical(attribute storage.Attribute) (storage.Attribute, error) {
+	fileAttribute, ok := attribute.(*storage.FileAttribute)
+	if !ok {
+		return attribute, nil
+	}
+	envelope, err := adapter.envelope(fileAttribute.Path())
+	if errors.Is(err, EnvelopeNotFound) {
+		return attribute, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 存储的校验值对应密文
+	return fileAttribute.WithFileSize(envelope.Size).WithMimeType(envelope.MimeType).WithoutChecksums(), nil
+}
+
+func (adapter *EncryptedAdapter) Info(file string) (storage.Attribute, error) {
+	attribute, err := adapter.adapter.Info(file)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.logical(attribute)
+}
+
+func (adapter *EncryptedAdapter) HasFile(file string) bool {
+	return adapter.adapter.HasFile(file)
+}
+
+func (adapter *EncryptedAdapter) HasDir(file string) bool {
+	return adapter.adapter.HasDir(file)
+}
+
+func (adapter *EncryptedAdapter) Read(file string) (io.ReadCloser, error) {
+	envelope, err := adapter.envelope(file)
+	if errors.Is(err, EnvelopeNotFound) && !adapter.adapter.HasFile(file) {
+		return nil, FileNotExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := adapter.adapter.Read(file)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := adapter.keys.UnwrapKey(envelope.KeyID, envelope.WrappedKey)
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+
+	return &decryptReader{
+		source: reader,
+		src:    bufio.NewReader(reader),
+		aead:   aead,
+		prefix: envelope.NoncePrefix,
+		chunk:  make([]byte, envelope.ChunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (adapter *EncryptedAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (bool, error) {
+	// 加密后无法识别文件类型, 在加密前识别
+	if mimeType == "" {
+		var err error
+		if mimeType, srcFile, err = detectReader(adapter.mimeDetector, dstFile, srcFile); err != nil {
+			return false, err
+		}
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return false, err
+	}
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return false, err
+	}
+
+	keyID, wrappedKey, err := adapter.keys.WrapKey(dataKey)
+	if err != nil {
+		return false, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return false, err
+	}
+
+	encrypter := &encryptReader{
+		src:    bufio.NewReaderSize(srcFile, adapter.chunkSize),
+		aead:   aead,
+		prefix: prefix,
+		plain:  make([]byte, adapter.chunkSize),
+	}
+	if _, err = adapter.adapter.Save(dstFile, encrypter, "application/octet-stream"); err != nil {
+		return false, err
+	}
+
+	err = adapter.envelopes.attach(dstFile, encryptionEnvelope{
+		Version:     encryptionVersion,
+		Algorithm:   encryptionAlgorithm,
+		KeyID:       keyID,
+		WrappedKey:  wrappedKey,
+		NoncePrefix: prefix,
+		ChunkSize:   adapter.chunkSize,
+		Size:        encrypter.size,
+		MimeType:    mimeType,
+	})
+	if err != nil {
+		return false, err
+	}
+	if err = adapter.recordKeyID(dstFile, keyID); err != nil {
+		_ = adapter.envelopes.remove(dstFile)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// recordKeyID 将主密钥ID写入文件的自定义元数据, 底层适配器不支持自定义元数据时跳过
+func (adapter *EncryptedAdapter) recordKeyID(file, keyID string) error {
+	err := SetMetadata(adapter.adapter, file, map[string]string{encryptionKeyMetadata: keyID}, true)
+	if errors.Is(err, MetadataUnsupported) {
+		return nil
+	}
+	return err
+}
+
+func (adapter *EncryptedAdapter) Cover(sourceImagePath, coverImagePath string, width, height uint) error {
+	return EncryptedCoverUnsupported
+}
+
+func (adapter *EncryptedAdapter) Copy(srcFile, dstFile string) (bool, error) {
+	if _, err := adapter.adapter.Copy(srcFile, dstFile); err != nil {
+		return false, err
+	}
+	if err := adapter.envelopes.copy(srcFile, dstFile); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (adapter *EncryptedAdapter) Move(dstFile, srcFile string) (bool, error) {
+	if _, err := adapter.Copy(srcFile, dstFile); err != nil {
+		return false, err
+	}
+	return adapter.Delete(srcFile)
+}
+
+func (adapter *EncryptedAdapter) Delete(file string) (bool, error) {
+	if err := adapter.envelopes.remove(file); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (adapter *EncryptedAdapter) MultipleDelete(fileList []string) (bool, error) {
+	return adapter.adapter.MultipleDelete(adapter.envelopes.withRecords(fileList))
+}
+
+func (adapter *EncryptedAdapter) MkDir(dir string, mode os.FileMode) (bool, error) {
+	return adapter.adapter.MkDir(dir, mode)
+}
+
+func (adapter *EncryptedAdapter) DeleteDir(dir string) (bool, error) {
+	return adapter.adapter.DeleteDir(dir)
+}
+
+// List 隐藏元数据目录, 并返回明文大小
+func (adapter *EncryptedAdapter) List(dir string, iterable func(attribute storage.Attribute)) error {
+	return adapter.envelopes.list(dir, iterable, adapter.logical)
+}
+
+// FullPath 直接访问该地址得到的是密文
+func (adapter *EncryptedAdapter) FullPath(path string) string {
+	return adapter.adapter.FullPath(path)
+}
+
+func (adapter *EncryptedAdapter) OriginalPath(fullPath string) string {
+	return adapter.adapter.OriginalPath(fullPath)
+}
+
+// Rewrap 使用当前主密钥重新加密文件的数据密钥, 不重新上传数据
+// @param file string 文件路径
+// @return bool 是否发生了轮换, 已使用当前主密钥时为false
+func (adapter *EncryptedAdapter) Rewrap(file string) (bool, error) {
+	envelope, err := adapter.envelope(file)
+	if err != nil {
+		return false, err
+	}
+	if envelope.KeyID == adapter.keys.CurrentKeyID() {
+		return false, nil
+	}
+
+	dataKey, err := adapter.keys.UnwrapKey(envelope.KeyID, envelope.WrappedKey)
+	if err != nil {
+		return false, err
+	}
+	envelope.KeyID, envelope.WrappedKey, err = adapter.keys.WrapKey(dataKey)
+	if err != nil {
+		return false, err
+	}
+
+	if err = adapter.envelopes.save(file, envelope); err != nil {
+		return false, err
+	}
+	if err = adapter.recordKeyID(file, envelope.KeyID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce 分块nonce: 7字节随机前缀 + 4字节分块序号 + 1字节末块标记
+// 末块标记防止密文被截断
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, noncePrefixSize+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], counter)
+	if last {
+		nonce[noncePrefixSize+4] = 1
+	}
+	return nonce
+}
+
+// encryptReader 分块加密读取器
+type encryptReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	plain   []byte
+	sealed  []byte
+	out     []byte
+	counter uint32
+	size    int64
+	done    bool
+}
+
+func (reader *encryptReader) Read(p []byte) (int, error) {
+	for len(reader.out) == 0 {
+		if reader.done {
+			return 0, io.EOF
+		}
+		if err := reader.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, reader.out)
+	reader.out = reader.out[n:]
+	return n, nil
+}
+
+func (reader *encryptReader) next() error {
+	n, err := io.ReadFull(reader.src, reader.plain)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	last := err != nil
+	if !last {
+		if _, err = reader.src.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if reader.counter == math.MaxUint32 && !last {
+		return errors.New("file is too large to encrypt")
+	}
+
+	reader.sealed = reader.aead.Seal(reader.sealed[:0], chunkNonce(reader.prefix, reader.counter, last), reader.plain[:n], nil)
+	reader.out = reader.sealed
+	reader.counter++
+	reader.size += int64(n)
+	reader.done = last
+
+	return nil
+}
+
+// decryptReader 分块解密读取器
+type decryptReader struct {
+	source  io.Closer
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	chunk   []byte
+	plain   []byte
+	out     []byte
+	counter uint32
+	done    bool
+}
+
+func (reader *decryptReader) Read(p []byte) (int, error) {
+	for len(reader.out) == 0 {
+		if reader.done {
+			return 0, io.EOF
+		}
+		if err := reader.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, reader.out)
+	reader.out = reader.out[n:]
+	return n, nil
+}
+
+func (reader *decryptReader) next() error {
+	n, err := io.ReadFull(reader.src, reader.chunk)
+	if err == io.EOF {
+		// 缺少末块, 密文被截断
+		return errors.Wrap(DecryptFailed, "truncated ciphertext")
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	last := err != nil
+	if !last {
+		if _, err = reader.src.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	reader.plain, err = reader.aead.Open(reader.plain[:0], chunkNonce(reader.prefix, reader.counter, last), reader.chunk[:n], nil)
+	if err != nil {
+		return errors.Wrap(DecryptFailed, err.Error())
+	}
+	reader.out = reader.plain
+	reader.counter++
+	reader.done = last
+
+	return nil
+}
+
+func (reader *decryptReader) Close() error {
+	return reader.source.Close()
+}
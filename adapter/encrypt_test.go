@@ -0,0 +1,276 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+const testChunkSize = 16
+
+func newTestEncryptedAdapter(t *testing.T) (Adapter, string) {
+	t.Helper()
+	dir := t.TempDir()
+	keys, err := NewStaticKeyProvider("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, dataKeySize)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := NewEncryptedAdapter(NewLocalAdapter(LocalConfig{BasePath: dir}), EncryptionConfig{
+		KeyProvider: keys,
+		ChunkSize:   testChunkSize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encrypted, dir
+}
+
+func readAll(adapter Adapter, file string) ([]byte, error) {
+	reader, err := adapter.Read(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	return io.ReadAll(reader)
+}
+
+func TestEncryptedAdapterRoundTrip(t *testing.T) {
+	encrypted, dir := newTestEncryptedAdapter(t)
+	sealedChunk := testChunkSize + 16
+
+	for _, size := range []int{0, 1, testChunkSize - 1, testChunkSize, testChunkSize + 1, 5*testChunkSize + 3} {
+		plain := make([]byte, size)
+		for i := range plain {
+			plain[i] = byte(i)
+		}
+		if _, err := encrypted.Save("file.bin", bytes.NewReader(plain), "application/octet-stream"); err != nil {
+			t.Fatalf("size %d: save: %v", size, err)
+		}
+
+		stored, err := os.ReadFile(filepath.Join(dir, "file.bin"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 末块带结束标记, 长度恰为分块整数倍时不追加空块, 空文件有一个空末块
+		chunks := (size + testChunkSize - 1) / testChunkSize
+		if chunks == 0 {
+			chunks = 1
+		}
+		if want := size + chunks*(sealedChunk-testChunkSize); len(stored) != want {
+			t.Errorf("size %d: stored %d bytes, want %d", size, len(stored), want)
+		}
+
+		got, err := readAll(encrypted, "file.bin")
+		if err != nil {
+			t.Fatalf("size %d: read: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: round trip mismatch", size)
+		}
+
+		attribute, err := encrypted.Info("file.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fileSize := attribute.(*storage.FileAttribute).FileSize(); fileSize != int64(size) {
+			t.Errorf("size %d: Info reports %d", size, fileSize)
+		}
+	}
+}
+
+func TestEncryptedAdapterTampering(t *testing.T) {
+	sealedChunk := testChunkSize + 16
+	plain := bytes.Repeat([]byte("0123456789abcdef"), 3) // 3个分块, 第3块为末块
+
+	tests := []struct {
+		name   string
+		tamper func(stored []byte) []byte
+	}{
+		{"last chunk dropped", func(stored []byte) []byte {
+			return stored[:2*sealedChunk]
+		}},
+		{"truncated mid chunk", func(stored []byte) []byte {
+			return stored[:sealedChunk+5]
+		}},
+		{"truncated to empty", func(stored []byte) []byte {
+			return nil
+		}},
+		{"chunks reordered", func(stored []byte) []byte {
+			reordered := append([]byte{}, stored[sealedChunk:2*sealedChunk]...)
+			reordered = append(reordered, stored[:sealedChunk]...)
+			return append(reordered, stored[2*sealedChunk:]...)
+		}},
+		{"bit flipped", func(stored []byte) []byte {
+			stored[3] ^= 1
+			return stored
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encrypted, dir := newTestEncryptedAdapter(t)
+			if _, err := encrypted.Save("file.bin", bytes.NewReader(plain), ""); err != nil {
+				t.Fatal(err)
+			}
+			filename := filepath.Join(dir, "file.bin")
+			stored, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = os.WriteFile(filename, test.tamper(stored), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = readAll(encrypted, "file.bin"); !errors.Is(err, DecryptFailed) {
+				t.Fatalf("read tampered file: got %v, want DecryptFailed", err)
+			}
+		})
+	}
+}
+
+func TestEncryptedAdapterEmptyFileTruncated(t *testing.T) {
+	encrypted, dir := newTestEncryptedAdapter(t)
+	if _, err := encrypted.Save("empty.bin", bytes.NewReader(nil), ""); err != nil {
+		t.Fatal(err)
+	}
+	// 空文件也有一个只含认证标签的末块, 删除后无法伪装为空文件
+	if err := os.WriteFile(filepath.Join(dir, "empty.bin"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readAll(encrypted, "empty.bin"); !errors.Is(err, DecryptFailed) {
+		t.Fatalf("got %v, want DecryptFailed", err)
+	}
+}
+
+func TestEncryptedAdapterMissingEnvelope(t *testing.T) {
+	encrypted, dir := newTestEncryptedAdapter(t)
+	if _, err := encrypted.Save("file.bin", bytes.NewReader([]byte("secret")), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, encrypted.(*EncryptedAdapter).envelopes.path("file.bin"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := encrypted.Read("file.bin"); !errors.Is(err, EnvelopeNotFound) {
+		t.Fatalf("read without envelope: got %v, want EnvelopeNotFound", err)
+	}
+	if _, err := encrypted.Read("missing.bin"); !errors.Is(err, FileNotExists) {
+		t.Fatalf("read missing file: got %v, want FileNotExists", err)
+	}
+}
+
+func TestEncryptedAdapterInvalidEnvelope(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		value any
+	}{
+		{"negative chunk size", "chunk_size", -1},
+		{"zero chunk size", "chunk_size", 0},
+		{"huge chunk size", "chunk_size", 1 << 40},
+		{"short nonce prefix", "nonce_prefix", []byte{1, 2}},
+		{"unknown version", "version", 99},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encrypted, dir := newTestEncryptedAdapter(t)
+			if _, err := encrypted.Save("file.bin", bytes.NewReader([]byte("secret")), ""); err != nil {
+				t.Fatal(err)
+			}
+			filename := filepath.Join(dir, encrypted.(*EncryptedAdapter).envelopes.path("file.bin"))
+			stored, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			envelope := map[string]any{}
+			if err = json.Unmarshal(stored, &envelope); err != nil {
+				t.Fatal(err)
+			}
+			envelope[test.field] = test.value
+			if stored, err = json.Marshal(envelope); err != nil {
+				t.Fatal(err)
+			}
+			if err = os.WriteFile(filename, stored, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = encrypted.Read("file.bin"); !errors.Is(err, EnvelopeInvalid) {
+				t.Fatalf("read with tampered envelope: got %v, want EnvelopeInvalid", err)
+			}
+		})
+	}
+}
+
+func TestEncryptedAdapterKeyIDMetadata(t *testing.T) {
+	dir := t.TempDir()
+	local := NewLocalAdapter(LocalConfig{BasePath: dir})
+	keys := map[string][]byte{"k1": bytes.Repeat([]byte{1}, dataKeySize), "k2": bytes.Repeat([]byte{2}, dataKeySize)}
+	open := func(current string) *EncryptedAdapter {
+		provider, err := NewStaticKeyProvider(current, keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encrypted, err := NewEncryptedAdapter(local, EncryptionConfig{KeyProvider: provider})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encrypted.(*EncryptedAdapter)
+	}
+
+	old := open("k1")
+	if _, err := old.Save("file.bin", bytes.NewReader([]byte("secret")), ""); err != nil {
+		t.Fatal(err)
+	}
+	if metadata, err := GetMetadata(old, "file.bin"); err != nil || metadata[encryptionKeyMetadata] != "k1" {
+		t.Fatalf("after save: got %v, %v, want key id k1", metadata, err)
+	}
+
+	rotated := open("k2")
+	if ok, err := rotated.Rewrap("file.bin"); err != nil || !ok {
+		t.Fatalf("rewrap: got %v, %v", ok, err)
+	}
+	if metadata, err := GetMetadata(rotated, "file.bin"); err != nil || metadata[encryptionKeyMetadata] != "k2" {
+		t.Fatalf("after rewrap: got %v, %v, want key id k2", metadata, err)
+	}
+	if got, err := readAll(rotated, "file.bin"); err != nil || string(got) != "secret" {
+		t.Fatalf("read after rewrap: got %q, %v", got, err)
+	}
+}
+
+func TestEncryptedAdapterSuffixedUserFile(t *testing.T) {
+	encrypted, _ := newTestEncryptedAdapter(t)
+	// 与元数据同后缀的用户文件不应被隐藏或覆盖元数据
+	files := map[string]string{"file.bin": "plain", "file.bin" + encryptionSuffix: "suffixed"}
+	for file, content := range files {
+		if _, err := encrypted.Save(file, bytes.NewReader([]byte(content)), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listed := map[string]bool{}
+	if err := encrypted.List("/", func(attribute storage.Attribute) {
+		if !attribute.IsDir() {
+			listed[attribute.Path()] = true
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != len(files) {
+		t.Errorf("listed %v, want only %d user files", listed, len(files))
+	}
+	for file, content := range files {
+		if !listed[file] {
+			t.Errorf("%s was not listed", file)
+		}
+		if got, err := readAll(encrypted, file); err != nil || string(got) != content {
+			t.Errorf("read %s: got %q, %v, want %q", file, got, err, content)
+		}
+	}
+}
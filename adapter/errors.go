@@ -0,0 +1,155 @@
+package adapter
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/huaweicloud/huaweicloud-sdk-go-obs/obs"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"github.com/tencentyun/cos-go-sdk-v5"
+)
+
+// ErrorKind 错误类别
+type ErrorKind string
+
+const (
+	ErrorKindNone         ErrorKind = ""
+	ErrorKindNotFound     ErrorKind = "not_found"
+	ErrorKindUnauthorized ErrorKind = "unauthorized"
+	ErrorKindForbidden    ErrorKind = "forbidden"
+	ErrorKindInvalid      ErrorKind = "invalid"
+	ErrorKindThrottled    ErrorKind = "throttled"
+	ErrorKindUnavailable  ErrorKind = "unavailable"
+	ErrorKindTimeout      ErrorKind = "timeout"
+	ErrorKindNetwork      ErrorKind = "network"
+	ErrorKindCanceled     ErrorKind = "canceled"
+	ErrorKindUnknown      ErrorKind = "unknown"
+)
+
+// ClassifyError 错误归类, 识别各云存储SDK的错误响应及网络错误
+func ClassifyError(err error) ErrorKind {
+	if err == nil {
+		return ErrorKindNone
+	}
+
+	switch {
+	case errors.Is(err, FileNotExists), errors.Is(err, BucketNotExists), errors.Is(err, os.ErrNotExist):
+		return ErrorKindNotFound
+	case errors.Is(err, FileNotReadable), errors.Is(err, FileNotWritable),
+		errors.Is(err, DirectoryNotWritable), errors.Is(err, os.ErrPermission):
+		return ErrorKindForbidden
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorKindTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorKindCanceled
+	}
+
+	var configErr *ConfigError
+	if errors.As(err, &configErr) {
+		return ErrorKindInvalid
+	}
+
+	if status, code := serviceErrorStatus(err); status > 0 || code != "" {
+		return classifyServiceError(status, code)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorKindTimeout
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorKindNetwork
+	}
+
+	return ErrorKindUnknown
+}
+
+// notExists 将各存储的不存在错误统一为FileNotExists, 其余错误原样返回
+func notExists(err error) error {
+	if ClassifyError(err) == ErrorKindNotFound {
+		return FileNotExists
+	}
+	return err
+}
+
+// serviceErrorStatus 提取各云存储SDK错误响应的HTTP状态码与错误码
+func serviceErrorStatus(err error) (int, string) {
+	var minioErr minio.ErrorResponse
+	if errors.As(err, &minioErr) {
+		return minioErr.StatusCode, minioErr.Code
+	}
+
+	var ossErr oss.ServiceError
+	if errors.As(err, &ossErr) {
+		return ossErr.StatusCode, ossErr.Code
+	}
+	var ossStatusErr oss.UnexpectedStatusCodeError
+	if errors.As(err, &ossStatusErr) {
+		return ossStatusErr.Got(), ""
+	}
+
+	var cosErr *cos.ErrorResponse
+	if errors.As(err, &cosErr) {
+		var status int
+		if cosErr.Response != nil {
+			status = cosErr.Response.StatusCode
+		}
+		return status, cosErr.Code
+	}
+
+	var obsErr obs.ObsError
+	if errors.As(err, &obsErr) {
+		return obsErr.StatusCode, obsErr.Code
+	}
+
+	return 0, ""
+}
+
+// classifyServiceError 根据HTTP状态码与错误码归类
+func classifyServiceError(status int, code string) ErrorKind {
+	switch code {
+	case "NoSuchKey", "NoSuchBucket", "NoSuchVersion", "NotFound":
+		return ErrorKindNotFound
+	case "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken", "InvalidToken",
+		"InvalidSecurityToken", "AuthorizationHeaderMalformed", "RequestTimeTooSkewed":
+		return ErrorKindUnauthorized
+	case "AccessDenied", "AccessForbidden":
+		return ErrorKindForbidden
+	case "SlowDown", "Throttling", "TooManyRequests", "RequestLimitExceeded", "ThrottlingException":
+		return ErrorKindThrottled
+	case "RequestTimeout":
+		return ErrorKindTimeout
+	case "InternalError", "ServiceUnavailable", "ServerBusy":
+		return ErrorKindUnavailable
+	}
+
+	switch {
+	case status == http.StatusUnauthorized:
+		return ErrorKindUnauthorized
+	case status == http.StatusForbidden:
+		return ErrorKindForbidden
+	case status == http.StatusNotFound:
+		return ErrorKindNotFound
+	case status == http.StatusRequestTimeout:
+		return ErrorKindTimeout
+	case status == http.StatusTooManyRequests:
+		return ErrorKindThrottled
+	case status >= http.StatusInternalServerError:
+		return ErrorKindUnavailable
+	case status >= http.StatusBadRequest:
+		return ErrorKindInvalid
+	}
+
+	return ErrorKindUnknown
+}
@@ -0,0 +1,26 @@
+package adapter
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dysodeng/filesystem/storage"
+)
+
+// withObjectHeader 根据对象存储响应头补充文件扩展属性
+// @param attribute *storage.FileAttribute 文件属性
+// @param header http.Header 响应头
+// @param prefix string 厂商请求头前缀, 如 x-oss-
+func withObjectHeader(attribute *storage.FileAttribute, header http.Header, prefix string) *storage.FileAttribute {
+	sseType, keyID := sseFromHeader(header)
+
+	return attribute.
+		WithServerSideEncryption(string(sseType), keyID).
+		WithETag(strings.Trim(header.Get("ETag"), `"`)).
+		WithChecksums(header.Get("Content-MD5"), header.Get(prefix+"hash-crc64ecma"), header.Get(prefix+"checksum-sha256")).
+		WithStorageClass(header.Get(prefix+"storage-class")).
+		WithContentHeaders(header.Get("Cache-Control"), header.Get("Content-Disposition")).
+		WithContentEncoding(header.Get("Content-Encoding")).
+		WithMetadata(userMetadata(header, prefix)).
+		WithVersionID(header.Get(prefix + "version-id"))
+}
@@ -0,0 +1,156 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthChecker 存储健康检查
+type HealthChecker interface {
+	// HealthCheck 检查存储是否可用
+	HealthCheck(ctx context.Context) HealthStatus
+}
+
+// HealthStatus 健康检查结果
+type HealthStatus struct {
+	Healthy bool
+	Skipped bool // 适配器未实现健康检查
+	Latency time.Duration
+	Kind    ErrorKind
+	Err     error
+}
+
+func newHealthStatus(start time.Time, err error) HealthStatus {
+	return HealthStatus{
+		Healthy: err == nil,
+		Latency: time.Since(start),
+		Kind:    ClassifyError(err),
+		Err:     err,
+	}
+}
+
+func (status HealthStatus) MarshalJSON() ([]byte, error) {
+	var message string
+	if status.Err != nil {
+		message = status.Err.Error()
+	}
+	return json.Marshal(map[string]interface{}{
+		"healthy":    status.Healthy,
+		"skipped":    status.Skipped,
+		"latency_ms": float64(status.Latency.Microseconds()) / 1000,
+		"kind":       status.Kind,
+		"error":      message,
+	})
+}
+
+// CheckHealth 检查适配器健康状态, 沿装饰器链查找HealthChecker
+// 未实现HealthChecker的适配器视为健康并标记为跳过
+func CheckHealth(ctx context.Context, adapter Adapter) HealthStatus {
+	checker, ok := As[HealthChecker](adapter)
+	if !ok {
+		return HealthStatus{Healthy: true, Skipped: true}
+	}
+	return checker.HealthCheck(ctx)
+}
+
+// runWithContext 在ctx内执行不支持context的调用
+func runWithContext(ctx context.Context, fn func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// HealthCheck 并发检查所有挂载的适配器
+func (manager *MountManager) HealthCheck(ctx context.Context) HealthStatus {
+	start := time.Now()
+	for _, status := range manager.CheckAll(ctx) {
+		if !status.Healthy {
+			return HealthStatus{
+				Healthy: false,
+				Latency: time.Since(start),
+				Kind:    status.Kind,
+				Err:     status.Err,
+			}
+		}
+	}
+	return HealthStatus{Healthy: true, Latency: time.Since(start)}
+}
+
+// CheckAll 并发检查所有挂载的适配器, key为挂载点
+func (manager *MountManager) CheckAll(ctx context.Context) map[string]HealthStatus {
+	mounts := manager.Mounts()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	result := make(map[string]HealthStatus, len(mounts))
+	for mount, adapter := range mounts {
+		wg.Add(1)
+		go func(mount string, adapter Adapter) {
+			defer wg.Done()
+			status := CheckHealth(ctx, adapter)
+			mu.Lock()
+			result[mount] = status
+			mu.Unlock()
+		}(mount, adapter)
+	}
+	wg.Wait()
+
+	return result
+}
+
+// HealthHandler 存储就绪探针
+// 所有挂载的适配器均健康时返回200, 否则返回503
+type HealthHandler struct {
+	manager *MountManager
+	timeout time.Duration
+}
+
+// NewHealthHandler 创建存储就绪探针
+// @param manager *MountManager 挂载管理器
+// @param timeout time.Duration 单次检查超时时间, 0表示不限制
+func NewHealthHandler(manager *MountManager, timeout time.Duration) *HealthHandler {
+	return &HealthHandler{
+		manager: manager,
+		timeout: timeout,
+	}
+}
+
+func (handler *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if handler.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, handler.timeout)
+		defer cancel()
+	}
+
+	checks := handler.manager.CheckAll(ctx)
+
+	code := http.StatusOK
+	status := "ok"
+	for _, check := range checks {
+		if !check.Healthy {
+			code = http.StatusServiceUnavailable
+			status = "unavailable"
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
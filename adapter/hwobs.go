@@ -1,15 +1,23 @@
 package adapter
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/dysodeng/filesystem/storage"
 	"github.com/huaweicloud/huaweicloud-sdk-go-obs/obs"
+	"github.com/pkg/errors"
 )
 
 // HwObsAdapter 华为云OBS存储适配器
@@ -19,65 +27,157 @@ type HwObsAdapter struct {
 }
 
 type HwObsConfig struct {
-	AccessKey      string
-	SecretKey      string
-	EndPoint       string
-	BucketName     string
-	StayBucketName string
-	IsPrivate      bool
+	AccessKey         string
+	SecretKey         string
+	EndPoint          string
+	BucketName        string
+	StayBucketName    string
+	IsPrivate         bool
+	ResolveVisibility bool                 // Info与List逐个查询对象ACL获取可见性, 每个文件多一次请求; 默认按IsPrivate返回
+	Probe             bool                 // 初始化时检查存储桶是否存在及凭证是否有效
+	Encryption        ServerSideEncryption // 服务端加密, 默认不加密
+	Logger            *slog.Logger         // 日志, 默认不输出
+	MimeDetector      MimeDetector         // 文件类型识别, 默认DefaultMimeDetector
 }
 
-func NewHwObsAdapter(config HwObsConfig) Adapter {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Println(err)
-		}
-	}()
+// validate 校验配置
+func (config HwObsConfig) validate() error {
+	switch {
+	case config.AccessKey == "":
+		return &ConfigError{Driver: "obs", Field: "AccessKey", Reason: "is required"}
+	case config.SecretKey == "":
+		return &ConfigError{Driver: "obs", Field: "SecretKey", Reason: "is required"}
+	case config.EndPoint == "":
+		return &ConfigError{Driver: "obs", Field: "EndPoint", Reason: "is required"}
+	case config.BucketName == "":
+		return &ConfigError{Driver: "obs", Field: "BucketName", Reason: "is required"}
+	}
+	return config.Encryption.validate("obs")
+}
+
+func init() {
+	Register("obs", newHwObsAdapterFromConfig, dsnParser("access_key", "secret_key", "endpoint", "bucket"))
+}
+
+// newHwObsAdapterFromConfig 根据驱动配置创建华为云OBS存储适配器
+func newHwObsAdapterFromConfig(config map[string]any) (Adapter, error) {
+	reader := newConfigReader("obs", config)
+	obsConfig := HwObsConfig{
+		AccessKey:         reader.string("access_key", true),
+		SecretKey:         reader.string("secret_key", true),
+		EndPoint:          reader.string("endpoint", true),
+		BucketName:        reader.string("bucket", true),
+		StayBucketName:    reader.string("stay_bucket", false),
+		IsPrivate:         reader.bool("private"),
+		ResolveVisibility: reader.bool("resolve_visibility"),
+		Probe:             reader.bool("probe"),
+		Encryption:        reader.encryption(),
+	}
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	return NewHwObsAdapter(obsConfig)
+}
 
-	hwObsAdapter := new(HwObsAdapter)
+func NewHwObsAdapter(config HwObsConfig) (Adapter, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	config.Logger = defaultLogger(config.Logger)
+	if config.MimeDetector == nil {
+		config.MimeDetector = DefaultMimeDetector
+	}
 
 	client, err := obs.New(config.AccessKey, config.SecretKey, config.EndPoint)
 	if err != nil {
-		panic("hw_obs connect error:" + err.Error())
+		return nil, errors.Wrap(err, "hw_obs connect error")
+	}
+
+	if config.Probe {
+		ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+		defer cancel()
+
+		err = runWithContext(ctx, func() error {
+			_, err := client.HeadBucket(config.BucketName)
+			return err
+		})
+		if err != nil {
+			if obsErr, ok := err.(obs.ObsError); ok && obsErr.StatusCode == http.StatusNotFound {
+				return nil, errors.Wrap(BucketNotExists, config.BucketName)
+			}
+			return nil, errors.Wrap(err, "hw_obs probe error")
+		}
 	}
 
-	hwObsAdapter.client = client
-	hwObsAdapter.config = config
+	return &HwObsAdapter{
+		client: client,
+		config: config,
+	}, nil
+}
 
-	return hwObsAdapter
+// mimeDetector 文件类型识别, 供装饰器沿用
+func (adapter *HwObsAdapter) mimeDetector() MimeDetector {
+	return adapter.config.MimeDetector
 }
 
-func (adapter *HwObsAdapter) Info(file string) (storage.Attribute, error) {
+func (adapter *HwObsAdapter) Info(file string) (_ storage.Attribute, err error) {
+	defer adapter.log(OperationInfo, file, time.Now(), &err)
 	input := &obs.GetObjectMetadataInput{
-		Bucket: adapter.config.BucketName,
-		Key:    file,
+		Bucket:    adapter.config.BucketName,
+		Key:       file,
+		SseHeader: adapter.customerKey(),
 	}
 	output, err := adapter.client.GetObjectMetadata(input)
 	if err != nil {
-		return nil, FileNotExists
+		return nil, notExists(err)
 	}
 
 	names := strings.Split(strings.TrimRight(file, "/"), "/")
+	mimeType := storedMimeType(adapter.config.MimeDetector, file, output.ContentType)
+	attribute := storage.NewFileAttribute(names[len(names)-1], file, adapter.fileVisibility(file), mimeType, output.ContentLength, output.LastModified.Unix()).
+		WithETag(strings.Trim(output.ETag, `"`)).
+		WithStorageClass(string(output.StorageClass)).
+		WithContentHeaders(output.CacheControl, output.ContentDisposition).
+		WithContentEncoding(output.ContentEncoding).
+		WithMetadata(lowerMetadata(output.Metadata)).
+		WithVersionID(output.VersionId)
+	if contentMD5, ok := output.ResponseHeaders["content-md5"]; ok && len(contentMD5) > 0 {
+		attribute.WithChecksums(contentMD5[0], "", "")
+	}
+	switch sseHeader := output.SseHeader.(type) {
+	case obs.SseCHeader:
+		attribute.WithServerSideEncryption(string(SSEC), "")
+	case obs.SseKmsHeader:
+		if strings.Contains(strings.ToLower(sseHeader.Encryption), "kms") {
+			attribute.WithServerSideEncryption(string(SSEKMS), sseHeader.Key)
+		} else {
+			attribute.WithServerSideEncryption(string(SSES3), "")
+		}
+	}
 
-	return storage.NewFileAttribute(names[len(names)-1], file, "", output.ContentType, output.ContentLength, output.LastModified.Unix()), nil
+	return attribute, nil
 }
 
 func (adapter *HwObsAdapter) HasFile(file string) bool {
-	_, err := adapter.Info(file)
-	if err != nil {
-		return false
-	}
-	return true
+	_, err := adapter.client.GetObjectMetadata(&obs.GetObjectMetadataInput{
+		Bucket:    adapter.config.BucketName,
+		Key:       file,
+		SseHeader: adapter.customerKey(),
+	})
+	return err == nil
 }
 
 func (adapter *HwObsAdapter) HasDir(file string) bool {
 	return true
 }
 
-func (adapter *HwObsAdapter) Read(file string) (io.ReadCloser, error) {
+func (adapter *HwObsAdapter) Read(file string) (_ io.ReadCloser, err error) {
+	defer adapter.log(OperationRead, file, time.Now(), &err)
 	input := &obs.GetObjectInput{}
 	input.Bucket = adapter.config.BucketName
 	input.Key = file
+	input.SseHeader = adapter.customerKey()
 
 	output, err := adapter.client.GetObject(input)
 	if err != nil {
@@ -87,25 +187,73 @@ func (adapter *HwObsAdapter) Read(file string) (io.ReadCloser, error) {
 	return output.Body, nil
 }
 
-func (adapter *HwObsAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (bool, error) {
+func (adapter *HwObsAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (_ bool, err error) {
+	defer adapter.log(OperationSave, dstFile, time.Now(), &err)
+	if mimeType == "" {
+		if mimeType, srcFile, err = detectReader(adapter.config.MimeDetector, dstFile, srcFile); err != nil {
+			return false, err
+		}
+	}
+	if _, err = adapter.put(dstFile, srcFile, mimeType, "", nil); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SaveWithChecksum 保存文件并校验上传内容, 上传后比对ETag中的MD5
+func (adapter *HwObsAdapter) SaveWithChecksum(dstFile string, srcFile io.Reader, mimeType string) (_ Checksums, err error) {
+	defer adapter.log(OperationSave, dstFile, time.Now(), &err)
+	if mimeType == "" {
+		if mimeType, srcFile, err = detectReader(adapter.config.MimeDetector, dstFile, srcFile); err != nil {
+			return Checksums{}, err
+		}
+	}
+	source, err := newChecksumSource(srcFile)
+	if err != nil {
+		return Checksums{}, err
+	}
+	reported, err := adapter.put(dstFile, source.Reader, mimeType, source.contentMD5(), nil)
+	if err != nil {
+		return Checksums{}, err
+	}
+	return verifyUpload(adapter, dstFile, source.sums(), reported)
+}
+
+// SaveEncoded 保存已压缩的内容, 设置Content-Encoding并在自定义元数据中记录压缩前的大小
+func (adapter *HwObsAdapter) SaveEncoded(dstFile string, srcFile io.Reader, mimeType string, encoding ContentEncoding, size int64) (err error) {
+	defer adapter.log(OperationSave, dstFile, time.Now(), &err)
+	_, err = adapter.put(dstFile, srcFile, mimeType, "", &encodedContent{encoding: encoding, size: size})
+	return err
+}
+
+// put 上传文件, 返回服务端记录的校验值
+func (adapter *HwObsAdapter) put(dstFile string, srcFile io.Reader, mimeType, contentMD5 string, encoded *encodedContent) (Checksums, error) {
 	input := &obs.PutObjectInput{
 		Body: srcFile,
 	}
 	input.Bucket = adapter.config.BucketName
 	input.Key = dstFile
-	if mimeType != "" {
-		input.ContentType = mimeType
+	input.SseHeader = adapter.sseHeader()
+	input.ContentType = mimeType
+	input.ContentMD5 = contentMD5
+	if encoded != nil {
+		input.ContentEncoding = string(encoded.encoding)
+		input.Metadata = map[string]string{uncompressedSizeMetadata: encoded.sizeValue()}
 	}
 
-	_, err := adapter.client.PutObject(input)
+	output, err := adapter.client.PutObject(input)
 	if err != nil {
-		return false, err
+		return Checksums{}, err
 	}
-
-	return true, nil
+	if !adapter.config.Encryption.etagIsContentMD5() {
+		return Checksums{}, nil
+	}
+	return Checksums{MD5: etagContentMD5(output.ETag)}, nil
 }
 
-func (adapter *HwObsAdapter) Cover(sourceImagePath, coverImagePath string, width, height uint) error {
+func (adapter *HwObsAdapter) Cover(sourceImagePath, coverImagePath string, width, height uint) (err error) {
+	defer adapter.log(OperationCover, sourceImagePath, time.Now(), &err)
 	style := "image/resize,m_lfit"
 	if width > 0 {
 		style += fmt.Sprintf(",w_%d", width)
@@ -136,37 +284,151 @@ func (adapter *HwObsAdapter) Cover(sourceImagePath, coverImagePath string, width
 	return nil
 }
 
-func (adapter *HwObsAdapter) Copy(srcFile, disFile string) (bool, error) {
+func (adapter *HwObsAdapter) Copy(srcFile, disFile string) (_ bool, err error) {
+	defer adapter.log(OperationCopy, srcFile, time.Now(), &err)
+	if err = adapter.copyObject(adapter, srcFile, disFile); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// CopyFrom 从同一区域、同一账号的其他存储桶服务端复制文件, 跨区域时回退为流式复制
+func (adapter *HwObsAdapter) CopyFrom(src Adapter, srcFile, dstFile string) (err error) {
+	source, ok := src.(*HwObsAdapter)
+	if !ok || source.config.EndPoint != adapter.config.EndPoint || source.config.AccessKey != adapter.config.AccessKey {
+		return CrossBucketUnsupported
+	}
+	defer adapter.log(OperationCopy, srcFile, time.Now(), &err)
+	return adapter.copyObject(source, srcFile, dstFile)
+}
+
+// copyObject 服务端复制, 源对象的SSE-C密钥取自源适配器
+// 源文件超过单次复制上限时使用 CopyPart 分片复制
+func (adapter *HwObsAdapter) copyObject(source *HwObsAdapter, srcFile, dstFile string) error {
 	input := &obs.CopyObjectInput{}
 	input.Bucket = adapter.config.BucketName
-	input.Key = disFile
-	input.CopySourceBucket = adapter.config.BucketName
+	input.Key = dstFile
+	input.CopySourceBucket = source.config.BucketName
 	input.CopySourceKey = srcFile
+	input.SseHeader = adapter.sseHeader()
+	input.SourceSseHeader = source.customerKey()
 
-	_, err := adapter.client.CopyObject(input)
+	var metadata *obs.GetObjectMetadataOutput
+	return copyWithFallback(maxSingleCopySize, func() error {
+		_, err := adapter.client.CopyObject(input)
+		return err
+	}, func() (int64, error) {
+		var err error
+		if metadata, err = source.metadata(srcFile); err != nil {
+			return 0, err
+		}
+		return metadata.ContentLength, nil
+	}, func(int64) error {
+		return adapter.copyMultipart(source, srcFile, dstFile, metadata)
+	})
+}
+
+// copyMultipart 分片复制, 失败时取消分片上传
+func (adapter *HwObsAdapter) copyMultipart(source *HwObsAdapter, srcFile, dstFile string, metadata *obs.GetObjectMetadataOutput) error {
+	input := &obs.InitiateMultipartUploadInput{}
+	input.Bucket = adapter.config.BucketName
+	input.Key = dstFile
+	input.ContentType = metadata.ContentType
+	input.Metadata = metadata.Metadata
+	input.StorageClass = metadata.StorageClass
+	input.SseHeader = adapter.sseHeader()
+	var upload *obs.InitiateMultipartUploadOutput
+	var err error
+	if metadata.ContentEncoding != "" {
+		// 初始化分片上传的参数不含内容编码, 以自定义请求头设置
+		upload, err = adapter.client.InitiateMultipartUpload(input, obs.WithCustomHeader("Content-Encoding", metadata.ContentEncoding))
+	} else {
+		upload, err = adapter.client.InitiateMultipartUpload(input)
+	}
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	return true, nil
+	parts := copyParts(metadata.ContentLength)
+	etags, err := copyPartsConcurrently(parts, func(part copyPart) (string, error) {
+		output, err := adapter.client.CopyPart(&obs.CopyPartInput{
+			Bucket:               adapter.config.BucketName,
+			Key:                  dstFile,
+			UploadId:             upload.UploadId,
+			PartNumber:           part.number,
+			CopySourceBucket:     source.config.BucketName,
+			CopySourceKey:        srcFile,
+			CopySourceRangeStart: part.start,
+			CopySourceRangeEnd:   part.end,
+			SseHeader:            adapter.customerKey(),
+			SourceSseHeader:      source.customerKey(),
+		})
+		if err != nil {
+			return "", err
+		}
+		return output.ETag, nil
+	})
+	if err == nil {
+		completed := make([]obs.Part, len(parts))
+		for i, part := range parts {
+			completed[i] = obs.Part{PartNumber: part.number, ETag: etags[i]}
+		}
+		_, err = adapter.client.CompleteMultipartUpload(&obs.CompleteMultipartUploadInput{
+			Bucket:   adapter.config.BucketName,
+			Key:      dstFile,
+			UploadId: upload.UploadId,
+			Parts:    completed,
+		})
+	}
+	if err != nil {
+		_, _ = adapter.client.AbortMultipartUpload(&obs.AbortMultipartUploadInput{
+			Bucket:   adapter.config.BucketName,
+			Key:      dstFile,
+			UploadId: upload.UploadId,
+		})
+		return err
+	}
+	return nil
+}
+
+// StayBucket 返回访问暂存桶的适配器
+func (adapter *HwObsAdapter) StayBucket() (Adapter, error) {
+	if adapter.config.StayBucketName == "" {
+		return nil, &ConfigError{Driver: "obs", Field: "StayBucketName", Reason: "is required"}
+	}
+	stay := *adapter
+	stay.config.BucketName, stay.config.StayBucketName = adapter.config.StayBucketName, ""
+	return &stay, nil
 }
 
-func (adapter *HwObsAdapter) Move(disFile, srcFile string) (bool, error) {
-	if _, err := adapter.Copy(srcFile, disFile); err != nil {
+// Promote 将暂存桶中的文件服务端复制到当前存储桶
+func (adapter *HwObsAdapter) Promote(srcFile, dstFile string) error {
+	stay, err := adapter.StayBucket()
+	if err != nil {
+		return err
+	}
+	return adapter.CopyFrom(stay, srcFile, dstFile)
+}
+
+func (adapter *HwObsAdapter) Move(disFile, srcFile string) (_ bool, err error) {
+	defer adapter.log(OperationMove, srcFile, time.Now(), &err)
+	if _, err = adapter.Copy(srcFile, disFile); err != nil {
 		return false, err
 	}
-	if _, err := adapter.Delete(srcFile); err != nil {
+	if _, err = adapter.Delete(srcFile); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-func (adapter *HwObsAdapter) Delete(file string) (bool, error) {
+func (adapter *HwObsAdapter) Delete(file string) (_ bool, err error) {
+	defer adapter.log(OperationDelete, file, time.Now(), &err)
 	input := &obs.DeleteObjectInput{}
 	input.Bucket = adapter.config.BucketName
 	input.Key = file
 
-	_, err := adapter.client.DeleteObject(input)
+	_, err = adapter.client.DeleteObject(input)
 	if err != nil {
 		return false, err
 	}
@@ -174,14 +436,35 @@ func (adapter *HwObsAdapter) Delete(file string) (bool, error) {
 	return true, nil
 }
 
-func (adapter *HwObsAdapter) MultipleDelete(fileList []string) (bool, error) {
+func (adapter *HwObsAdapter) MultipleDelete(fileList []string) (_ bool, err error) {
+	defer adapter.log(OperationMultipleDelete, "", time.Now(), &err)
+	for _, chunk := range batchChunks(fileList, maxBatchKeys) {
+		var results []BatchResult
+		if results, err = adapter.deleteObjects(chunk); err != nil {
+			return false, err
+		}
+		if err = batchError(results); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// DeleteObjects 批量删除文件, 返回每个文件的删除结果
+func (adapter *HwObsAdapter) DeleteObjects(fileList []string) (_ []BatchResult, err error) {
+	defer adapter.log(OperationMultipleDelete, "", time.Now(), &err)
+	return adapter.deleteObjects(fileList)
+}
+
+func (adapter *HwObsAdapter) deleteObjects(fileList []string) ([]BatchResult, error) {
 	length := len(fileList)
 	if length <= 0 {
-		return true, nil
+		return nil, nil
 	}
 
 	input := &obs.DeleteObjectsInput{
 		Bucket: adapter.config.BucketName,
+		Quiet:  true,
 	}
 	objects := make([]obs.ObjectToDelete, length)
 	for i := 0; i < length; i++ {
@@ -189,23 +472,30 @@ func (adapter *HwObsAdapter) MultipleDelete(fileList []string) (bool, error) {
 	}
 	input.Objects = objects
 
-	_, err := adapter.client.DeleteObjects(input)
+	output, err := adapter.client.DeleteObjects(input)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	return true, nil
+	failed := make(map[string]error)
+	for _, e := range output.Errors {
+		failed[e.Key] = errors.Errorf("%s: %s", e.Code, e.Message)
+	}
+	return batchResults(fileList, failed), nil
 }
 
-func (adapter *HwObsAdapter) MkDir(dir string, mode os.FileMode) (bool, error) {
+func (adapter *HwObsAdapter) MkDir(dir string, mode os.FileMode) (_ bool, err error) {
+	defer adapter.log(OperationMkDir, dir, time.Now(), &err)
 	return true, nil
 }
 
-func (adapter *HwObsAdapter) DeleteDir(dir string) (bool, error) {
+func (adapter *HwObsAdapter) DeleteDir(dir string) (_ bool, err error) {
+	defer adapter.log(OperationDeleteDir, dir, time.Now(), &err)
 	return true, nil
 }
 
-func (adapter *HwObsAdapter) List(dir string, iterable func(attribute storage.Attribute)) error {
+func (adapter *HwObsAdapter) List(dir string, iterable func(attribute storage.Attribute)) (err error) {
+	defer adapter.log(OperationList, dir, time.Now(), &err)
 	dir = strings.TrimRight(dir, "/") + "/"
 	input := &obs.ListObjectsInput{
 		Bucket: adapter.config.BucketName,
@@ -215,21 +505,45 @@ func (adapter *HwObsAdapter) List(dir string, iterable func(attribute storage.At
 	}
 
 	input.Prefix = dir
-	output, err := adapter.client.ListObjects(input)
-	if err != nil {
-		return err
-	}
+	input.Delimiter = "/"
+	input.MaxKeys = 1000
 
-	for _, prefix := range output.CommonPrefixes {
-		names := strings.Split(strings.TrimRight(prefix, "/"), "/")
-		iterable(storage.NewDirectoryAttribute(names[len(names)-1], prefix, "", 0))
-	}
-	for _, content := range output.Contents {
-		names := strings.Split(strings.TrimRight(content.Key, "/"), "/")
-		iterable(storage.NewFileAttribute(names[len(names)-1], content.Key, "", "", content.Size, content.LastModified.Unix()))
+	for {
+		output, err := adapter.client.ListObjects(input)
+		if err != nil {
+			return err
+		}
+
+		for _, prefix := range output.CommonPrefixes {
+			names := strings.Split(strings.TrimRight(prefix, "/"), "/")
+			iterable(storage.NewDirectoryAttribute(names[len(names)-1], prefix, defaultVisibility(adapter.config.IsPrivate), 0))
+		}
+		for _, content := range output.Contents {
+			if content.Key == dir {
+				continue
+			}
+			names := strings.Split(strings.TrimRight(content.Key, "/"), "/")
+			mimeType := adapter.config.MimeDetector.Detect(content.Key, nil)
+			iterable(storage.NewFileAttribute(names[len(names)-1], content.Key, adapter.fileVisibility(content.Key), mimeType, content.Size, content.LastModified.Unix()).
+				WithETag(strings.Trim(content.ETag, `"`)).
+				WithStorageClass(string(content.StorageClass)))
+		}
+
+		if !output.IsTruncated {
+			return nil
+		}
+		input.Marker = output.NextMarker
 	}
+}
 
-	return nil
+// HealthCheck 检查存储桶是否可访问
+func (adapter *HwObsAdapter) HealthCheck(ctx context.Context) HealthStatus {
+	start := time.Now()
+	err := runWithContext(ctx, func() error {
+		_, err := adapter.client.HeadBucket(adapter.config.BucketName)
+		return err
+	})
+	return newHealthStatus(start, err)
 }
 
 func (adapter *HwObsAdapter) FullPath(path string) string {
@@ -256,3 +570,368 @@ func (adapter *HwObsAdapter) OriginalPath(fullPath string) string {
 	}
 	return strings.TrimLeft(u.Path, "/")
 }
+
+// SignURL 生成预签名URL
+func (adapter *HwObsAdapter) SignURL(path, method string, expires time.Duration) (string, error) {
+	output, err := adapter.client.CreateSignedUrl(&obs.CreateSignedUrlInput{
+		Method:  obs.HttpMethodType(method),
+		Bucket:  adapter.config.BucketName,
+		Key:     path,
+		Expires: int(expires / time.Second),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.Replace(output.SignedUrl, "http://", "https://", 1), nil
+}
+
+// SetVisibility 设置对象ACL
+func (adapter *HwObsAdapter) SetVisibility(path string, visibility storage.Visibility) error {
+	if err := validVisibility(visibility); err != nil {
+		return err
+	}
+	input := &obs.SetObjectAclInput{
+		Bucket: adapter.config.BucketName,
+		Key:    path,
+		ACL:    obs.AclPublicRead,
+	}
+	if visibility == storage.VisibilityPrivate {
+		input.ACL = obs.AclPrivate
+	}
+	_, err := adapter.client.SetObjectAcl(input)
+	return err
+}
+
+// Visibility 根据对象ACL获取可见性, 继承存储桶ACL时按配置的存储桶可见性
+func (adapter *HwObsAdapter) Visibility(path string) (storage.Visibility, error) {
+	visibility, err := adapter.objectVisibility(path)
+	if err != nil {
+		return "", err
+	}
+	if visibility == "" {
+		return defaultVisibility(adapter.config.IsPrivate), nil
+	}
+	return visibility, nil
+}
+
+// fileVisibility 开启ResolveVisibility时查询对象ACL, 否则或查询失败时按配置的存储桶可见性
+func (adapter *HwObsAdapter) fileVisibility(path string) storage.Visibility {
+	if adapter.config.ResolveVisibility {
+		if visibility, err := adapter.Visibility(path); err == nil {
+			return visibility
+		}
+	}
+	return defaultVisibility(adapter.config.IsPrivate)
+}
+
+// objectVisibility 对象ACL的可见性, 继承存储桶ACL(Delivered)时返回空
+func (adapter *HwObsAdapter) objectVisibility(path string) (storage.Visibility, error) {
+	output, err := adapter.client.GetObjectAcl(&obs.GetObjectAclInput{
+		Bucket: adapter.config.BucketName,
+		Key:    path,
+	})
+	if err != nil {
+		return "", notExists(err)
+	}
+	for _, grant := range output.Grants {
+		if grant.Grantee.URI == obs.GroupAllUsers && (grant.Permission == obs.PermissionRead || grant.Permission == obs.PermissionFullControl) {
+			return storage.VisibilityPublic, nil
+		}
+	}
+	if output.Delivered == "true" {
+		return "", nil
+	}
+	return storage.VisibilityPrivate, nil
+}
+
+// GetMetadata 获取对象自定义元数据(x-obs-meta-*)
+func (adapter *HwObsAdapter) GetMetadata(path string) (map[string]string, error) {
+	output, err := adapter.metadata(path)
+	if err != nil {
+		return nil, err
+	}
+	return lowerMetadata(output.Metadata), nil
+}
+
+// SetMetadata 以 REPLACE 方式原地复制对象写入自定义元数据, 保留内容类型等标准请求头、存储类型及对象ACL
+func (adapter *HwObsAdapter) SetMetadata(path string, metadata map[string]string, merge bool) error {
+	output, err := adapter.metadata(path)
+	if err != nil {
+		return err
+	}
+	metadata, err = resolveMetadata(lowerMetadata(output.Metadata), metadata, merge)
+	if err != nil {
+		return err
+	}
+	visibility, err := adapter.objectVisibility(path)
+	if err != nil {
+		return err
+	}
+
+	input := &obs.CopyObjectInput{}
+	input.Bucket = adapter.config.BucketName
+	input.Key = path
+	input.CopySourceBucket = adapter.config.BucketName
+	input.CopySourceKey = path
+	input.MetadataDirective = obs.ReplaceMetadata
+	input.Metadata = metadata
+	input.CacheControl = output.CacheControl
+	input.ContentDisposition = output.ContentDisposition
+	input.ContentEncoding = output.ContentEncoding
+	input.ContentLanguage = output.ContentLanguage
+	input.ContentType = output.ContentType
+	input.Expires = output.HttpExpires
+	input.StorageClass = output.StorageClass
+	input.SseHeader = adapter.sseHeader()
+	input.SourceSseHeader = adapter.customerKey()
+	// 复制时对象ACL会重置为继承存储桶, 需显式保留
+	switch visibility {
+	case storage.VisibilityPublic:
+		input.ACL = obs.AclPublicRead
+	case storage.VisibilityPrivate:
+		input.ACL = obs.AclPrivate
+	}
+
+	_, err = adapter.client.CopyObject(input)
+	return err
+}
+
+// PutTags 设置对象标签
+func (adapter *HwObsAdapter) PutTags(path string, tags map[string]string) error {
+	if err := validTags(tags); err != nil {
+		return err
+	}
+	tagging := obsObjectTagging{TagSet: make([]obs.Tag, 0, len(tags))}
+	for _, key := range sortedTagKeys(tags) {
+		tagging.TagSet = append(tagging.TagSet, obs.Tag{Key: key, Value: tags[key]})
+	}
+	body, err := xml.Marshal(tagging)
+	if err != nil {
+		return err
+	}
+	return notExists(adapter.tagging(obs.HttpMethodPut, path, body, nil))
+}
+
+// GetTags 获取对象标签, 未设置标签时返回空
+func (adapter *HwObsAdapter) GetTags(path string) (map[string]string, error) {
+	var tagging obsObjectTagging
+	if err := adapter.tagging(obs.HttpMethodGet, path, nil, &tagging); err != nil {
+		var obsErr obs.ObsError
+		if errors.As(err, &obsErr) && obsErr.Code == "NoSuchTagSet" {
+			return map[string]string{}, nil
+		}
+		return nil, notExists(err)
+	}
+	tags := make(map[string]string, len(tagging.TagSet))
+	for _, tag := range tagging.TagSet {
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
+
+// DeleteTags 删除对象标签
+func (adapter *HwObsAdapter) DeleteTags(path string) error {
+	return notExists(adapter.tagging(obs.HttpMethodDelete, path, nil, nil))
+}
+
+// obsObjectTagging 对象标签请求与响应体
+type obsObjectTagging struct {
+	XMLName xml.Name  `xml:"Tagging"`
+	TagSet  []obs.Tag `xml:"TagSet>Tag"`
+}
+
+// tagging 请求对象的 ?tagging 子资源
+// 当前SDK版本只提供存储桶标签接口, 对象标签以SDK签名的临时URL直接请求, 失败时按SDK的 obs.ObsError 返回
+// @param method obs.HttpMethodType 请求方法
+// @param path string 文件路径
+// @param body []byte 请求体, 无请求体时为nil
+// @param result any 响应体解析目标, 不需要时为nil
+func (adapter *HwObsAdapter) tagging(method obs.HttpMethodType, path string, body []byte, result any) error {
+	var headers map[string]string
+	if body != nil {
+		sum := md5.Sum(body)
+		headers = map[string]string{"Content-MD5": base64.StdEncoding.EncodeToString(sum[:])}
+	}
+	signed, err := adapter.client.CreateSignedUrl(&obs.CreateSignedUrlInput{
+		Method:      method,
+		Bucket:      adapter.config.BucketName,
+		Key:         path,
+		SubResource: obs.SubResourceTagging,
+		Expires:     300,
+		Headers:     headers,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(string(method), signed.SignedUrl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = signed.ActualSignedRequestHeaders.Clone()
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= http.StatusMultipleChoices {
+		obsErr := obs.ObsError{Status: res.Status}
+		_ = xml.Unmarshal(content, &obsErr)
+		obsErr.StatusCode = res.StatusCode
+		obsErr.RequestId = res.Header.Get("x-obs-request-id")
+		return obsErr
+	}
+	if result == nil {
+		return nil
+	}
+	return xml.Unmarshal(content, result)
+}
+
+// ListVersions 列出对象的全部版本及删除标记
+func (adapter *HwObsAdapter) ListVersions(path string) ([]ObjectVersion, error) {
+	var versions []ObjectVersion
+	input := &obs.ListVersionsInput{Bucket: adapter.config.BucketName}
+	input.Prefix = path
+	for {
+		output, err := adapter.client.ListVersions(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range output.Versions {
+			if version.Key == path {
+				versions = append(versions, ObjectVersion{
+					VersionID:    version.VersionId,
+					IsLatest:     version.IsLatest,
+					Size:         version.Size,
+					ETag:         strings.Trim(version.ETag, `"`),
+					LastModified: version.LastModified,
+				})
+			}
+		}
+		for _, marker := range output.DeleteMarkers {
+			if marker.Key == path {
+				versions = append(versions, ObjectVersion{
+					VersionID:      marker.VersionId,
+					IsLatest:       marker.IsLatest,
+					IsDeleteMarker: true,
+					LastModified:   marker.LastModified,
+				})
+			}
+		}
+		if !output.IsTruncated {
+			break
+		}
+		input.KeyMarker, input.VersionIdMarker = output.NextKeyMarker, output.NextVersionIdMarker
+	}
+	if len(versions) == 0 {
+		return nil, FileNotExists
+	}
+	return sortVersions(versions), nil
+}
+
+// ReadVersion 读取对象的指定版本
+func (adapter *HwObsAdapter) ReadVersion(path, versionID string) (io.ReadCloser, error) {
+	input := &obs.GetObjectInput{}
+	input.Bucket = adapter.config.BucketName
+	input.Key = path
+	input.VersionId = versionID
+	input.SseHeader = adapter.customerKey()
+
+	output, err := adapter.client.GetObject(input)
+	if err != nil {
+		return nil, notExists(err)
+	}
+	return output.Body, nil
+}
+
+// RestoreVersion 将指定版本复制为当前版本
+func (adapter *HwObsAdapter) RestoreVersion(path, versionID string) error {
+	input := &obs.CopyObjectInput{}
+	input.Bucket = adapter.config.BucketName
+	input.Key = path
+	input.CopySourceBucket = adapter.config.BucketName
+	input.CopySourceKey = path
+	input.CopySourceVersionId = versionID
+	input.SseHeader = adapter.sseHeader()
+	input.SourceSseHeader = adapter.customerKey()
+
+	_, err := adapter.client.CopyObject(input)
+	return notExists(err)
+}
+
+// DeleteVersion 永久删除对象的指定版本
+func (adapter *HwObsAdapter) DeleteVersion(path, versionID string) error {
+	_, err := adapter.client.DeleteObject(&obs.DeleteObjectInput{
+		Bucket:    adapter.config.BucketName,
+		Key:       path,
+		VersionId: versionID,
+	})
+	return notExists(err)
+}
+
+// metadata 读取对象元信息
+func (adapter *HwObsAdapter) metadata(path string) (*obs.GetObjectMetadataOutput, error) {
+	output, err := adapter.client.GetObjectMetadata(&obs.GetObjectMetadataInput{
+		Bucket:    adapter.config.BucketName,
+		Key:       path,
+		SseHeader: adapter.customerKey(),
+	})
+	if err != nil {
+		return nil, notExists(err)
+	}
+	return output, nil
+}
+
+// lowerMetadata 元数据键统一为小写
+func lowerMetadata(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		result[strings.ToLower(key)] = value
+	}
+	return result
+}
+
+// WithServerSideEncryption 返回使用指定服务端加密方式的适配器副本
+func (adapter *HwObsAdapter) WithServerSideEncryption(sse ServerSideEncryption) Adapter {
+	clone := *adapter
+	clone.config.Encryption = sse
+	return &clone
+}
+
+// sseHeader 上传及复制目标使用的服务端加密请求头
+func (adapter *HwObsAdapter) sseHeader() obs.ISseHeader {
+	sse := adapter.config.Encryption
+	switch sse.Type {
+	case SSES3:
+		return obs.SseKmsHeader{Encryption: "AES256"}
+	case SSEKMS:
+		return obs.SseKmsHeader{Encryption: obs.DEFAULT_SSE_KMS_ENCRYPTION_OBS, Key: sse.KMSKeyID}
+	case SSEC:
+		return adapter.customerKey()
+	}
+	return nil
+}
+
+// customerKey 读取SSE-C加密对象时需携带的客户密钥, 其他加密方式返回nil
+func (adapter *HwObsAdapter) customerKey() obs.ISseHeader {
+	if adapter.config.Encryption.Type != SSEC {
+		return nil
+	}
+	key, keyMD5 := adapter.config.Encryption.customerKey()
+	return obs.SseCHeader{Encryption: obs.DEFAULT_SSE_C_ENCRYPTION, Key: key, KeyMD5: keyMD5}
+}
+
+// log 记录操作日志
+func (adapter *HwObsAdapter) log(operation, path string, start time.Time, err *error) {
+	logOperation(adapter.config.Logger, "obs", operation, path, start, err)
+}
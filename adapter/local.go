@@ -1,17 +1,20 @@
 package adapter
 
 import (
+	"context"
 	"io"
 	"io/fs"
-	"log"
-	"net/http"
+	"log/slog"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
 )
 
 // LocalAdapter 本地文件存储适配器
@@ -20,13 +23,47 @@ type LocalAdapter struct {
 }
 
 type LocalConfig struct {
-	BasePath  string
-	LogicPath string
-	BaseUrl   string
+	BasePath     string
+	LogicPath    string
+	BaseUrl      string
+	Logger       *slog.Logger // 日志, 默认不输出
+	MimeDetector MimeDetector // 文件类型识别, 默认DefaultMimeDetector
+	Versioning   bool         // 覆盖及删除时将原文件保留到 .versions 目录
+}
+
+func init() {
+	Register("local", newLocalAdapterFromConfig, func(dsn *url.URL) (map[string]any, error) {
+		config, err := dsnParser("", "", "", "")(dsn)
+		if err != nil {
+			return nil, err
+		}
+		// local:///data/upload 或 local://./upload
+		config["base_path"] = dsn.Host + dsn.Path
+		return config, nil
+	})
+}
+
+// newLocalAdapterFromConfig 根据驱动配置创建本地存储适配器
+func newLocalAdapterFromConfig(config map[string]any) (Adapter, error) {
+	reader := newConfigReader("local", config)
+	localConfig := LocalConfig{
+		BasePath:   reader.string("base_path", true),
+		LogicPath:  reader.string("logic_path", false),
+		BaseUrl:    reader.string("base_url", false),
+		Versioning: reader.bool("versioning"),
+	}
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	return NewLocalAdapter(localConfig), nil
 }
 
 func NewLocalAdapter(config LocalConfig) Adapter {
 	config.BasePath = strings.TrimRight(config.BasePath, "/") + "/"
+	config.Logger = defaultLogger(config.Logger)
+	if config.MimeDetector == nil {
+		config.MimeDetector = DefaultMimeDetector
+	}
 	return &LocalAdapter{
 		config: config,
 	}
@@ -48,7 +85,6 @@ func (adapter *LocalAdapter) isReadable(filename string) bool {
 
 // isWritable 是否有可写权限
 func (adapter *LocalAdapter) isWritable(filename string) bool {
-	log.Println(adapter.absolutePath(filename))
 	err := syscall.Access(adapter.absolutePath(filename), syscall.O_RDWR)
 	if err != nil {
 		return false
@@ -56,7 +92,28 @@ func (adapter *LocalAdapter) isWritable(filename string) bool {
 	return true
 }
 
-func (adapter *LocalAdapter) Info(file string) (storage.Attribute, error) {
+// mimeType 读取文件头部识别文件类型
+func (adapter *LocalAdapter) mimeType(filename string) string {
+	f, err := os.Open(adapter.absolutePath(filename))
+	if err != nil {
+		return adapter.config.MimeDetector.Detect(filename, nil)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	header := make([]byte, sniffLength)
+	n, _ := io.ReadFull(f, header)
+	return adapter.config.MimeDetector.Detect(filename, header[:n])
+}
+
+// mimeDetector 文件类型识别, 供装饰器沿用
+func (adapter *LocalAdapter) mimeDetector() MimeDetector {
+	return adapter.config.MimeDetector
+}
+
+func (adapter *LocalAdapter) Info(file string) (_ storage.Attribute, err error) {
+	defer adapter.log(OperationInfo, file, time.Now(), &err)
 	info, err := os.Stat(adapter.absolutePath(file))
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -70,18 +127,17 @@ func (adapter *LocalAdapter) Info(file string) (storage.Attribute, error) {
 	}
 
 	if info.IsDir() {
-		return storage.NewDirectoryAttribute(info.Name(), file, "", info.ModTime().Unix()), nil
+		return storage.NewDirectoryAttribute(info.Name(), file, modeVisibility(info.Mode()), info.ModTime().Unix()), nil
 	}
 
-	// mime type
-	f, _ := os.Open(adapter.absolutePath(file))
-
-	buffer := make([]byte, 512)
-	_, _ = f.Read(buffer)
-
-	contentType := http.DetectContentType(buffer)
-
-	return storage.NewFileAttribute(info.Name(), file, "", contentType, info.Size(), info.ModTime().Unix()), nil
+	metadata, _ := adapter.GetMetadata(file)
+	attribute := storage.NewFileAttribute(info.Name(), file, modeVisibility(info.Mode()), adapter.mimeType(file), info.Size(), info.ModTime().Unix()).
+		WithMetadata(metadata)
+	var sums Checksums
+	if adapter.checksums().load(file, &sums) == nil {
+		attribute.WithChecksums(sums.MD5, sums.CRC64, sums.SHA256)
+	}
+	return attribute, nil
 }
 
 func (adapter *LocalAdapter) HasFile(file string) bool {
@@ -110,7 +166,8 @@ func (adapter *LocalAdapter) HasDir(file string) bool {
 	return true
 }
 
-func (adapter *LocalAdapter) Read(file string) (io.ReadCloser, error) {
+func (adapter *LocalAdapter) Read(file string) (_ io.ReadCloser, err error) {
+	defer adapter.log(OperationRead, file, time.Now(), &err)
 	if !adapter.HasFile(file) {
 		return nil, FileNotExists
 	}
@@ -127,8 +184,19 @@ func (adapter *LocalAdapter) Read(file string) (io.ReadCloser, error) {
 	return f, nil
 }
 
-func (adapter *LocalAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (bool, error) {
-	dst, err := os.OpenFile(adapter.absolutePath(dstFile), os.O_WRONLY|os.O_CREATE, os.FileMode(0644))
+func (adapter *LocalAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (_ bool, err error) {
+	defer adapter.log(OperationSave, dstFile, time.Now(), &err)
+	restore, err := adapter.archive(dstFile)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			restore()
+		}
+	}()
+
+	dst, err := os.OpenFile(adapter.absolutePath(dstFile), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(0644))
 	if err != nil {
 		return false, err
 	}
@@ -141,29 +209,68 @@ func (adapter *LocalAdapter) Save(dstFile string, srcFile io.Reader, mimeType st
 		return false, err
 	}
 
+	// 覆盖写入时清除原文件的自定义元数据及标签
+	if !adapter.isSidecar(dstFile) {
+		for _, s := range adapter.sidecars() {
+			if err = s.delete(dstFile); err != nil {
+				return false, err
+			}
+		}
+	}
+
 	return true, nil
 }
 
-func (adapter *LocalAdapter) Cover(sourceImagePath, coverImagePath string, width, height uint) error {
+// SaveWithChecksum 保存文件并在 .sidecars/<文件路径>.fssum 中记录内容校验值, Info 返回记录的校验值
+// 覆盖写入、复制、删除时校验值文件随文件更新
+func (adapter *LocalAdapter) SaveWithChecksum(dstFile string, srcFile io.Reader, mimeType string) (_ Checksums, err error) {
+	defer adapter.log(OperationSave, dstFile, time.Now(), &err)
+	hashes := newChecksumHashes()
+	if _, err = adapter.Save(dstFile, io.TeeReader(srcFile, hashes), mimeType); err != nil {
+		return Checksums{}, err
+	}
+	sums := hashes.sums()
+	if err = adapter.checksums().save(dstFile, sums); err != nil {
+		return Checksums{}, err
+	}
+	return sums, nil
+}
+
+func (adapter *LocalAdapter) Cover(sourceImagePath, coverImagePath string, width, height uint) (err error) {
+	defer adapter.log(OperationCover, sourceImagePath, time.Now(), &err)
 	// TODO
 	return nil
 }
 
-func (adapter *LocalAdapter) Copy(srcFile, dstFile string) (bool, error) {
-	dst, err := os.OpenFile(adapter.absolutePath(dstFile), os.O_RDWR|os.O_CREATE, os.FileMode(0644))
+func (adapter *LocalAdapter) Copy(srcFile, dstFile string) (_ bool, err error) {
+	defer adapter.log(OperationCopy, srcFile, time.Now(), &err)
+	src, err := os.Open(adapter.absolutePath(srcFile))
 	if err != nil {
+		if os.IsNotExist(err) {
+			return false, FileNotExists
+		}
 		return false, err
 	}
 	defer func() {
-		_ = dst.Close()
+		_ = src.Close()
 	}()
 
-	src, err := os.Open(adapter.absolutePath(srcFile))
+	restore, err := adapter.archive(dstFile)
 	if err != nil {
 		return false, err
 	}
 	defer func() {
-		_ = src.Close()
+		if err != nil {
+			restore()
+		}
+	}()
+
+	dst, err := os.OpenFile(adapter.absolutePath(dstFile), os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(0644))
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = dst.Close()
 	}()
 
 	_, err = io.Copy(dst, src)
@@ -171,22 +278,32 @@ func (adapter *LocalAdapter) Copy(srcFile, dstFile string) (bool, error) {
 		return false, err
 	}
 
+	if !adapter.isSidecar(srcFile) {
+		for _, s := range adapter.sidecars() {
+			if err = s.copy(srcFile, dstFile); err != nil {
+				return false, err
+			}
+		}
+	}
+
 	return true, nil
 }
 
-func (adapter *LocalAdapter) Move(dstFile, srcFile string) (bool, error) {
-	if _, err := adapter.Copy(srcFile, dstFile); err != nil {
+func (adapter *LocalAdapter) Move(dstFile, srcFile string) (_ bool, err error) {
+	defer adapter.log(OperationMove, srcFile, time.Now(), &err)
+	if _, err = adapter.Copy(srcFile, dstFile); err != nil {
 		return false, err
 	}
 
-	if _, err := adapter.Delete(srcFile); err != nil {
+	if _, err = adapter.Delete(srcFile); err != nil {
 		return false, err
 	}
 
 	return true, nil
 }
 
-func (adapter *LocalAdapter) Delete(file string) (bool, error) {
+func (adapter *LocalAdapter) Delete(file string) (_ bool, err error) {
+	defer adapter.log(OperationDelete, file, time.Now(), &err)
 	if !adapter.HasFile(file) {
 		return false, FileNotExists
 	}
@@ -194,55 +311,91 @@ func (adapter *LocalAdapter) Delete(file string) (bool, error) {
 		return false, DirectoryNotWritable
 	}
 
-	if err := os.Remove(adapter.absolutePath(file)); err != nil {
+	if _, err = adapter.archive(file); err != nil {
+		return false, err
+	}
+	if err = os.Remove(adapter.absolutePath(file)); err != nil && !os.IsNotExist(err) {
 		return false, err
 	}
 
+	if !adapter.isSidecar(file) {
+		for _, s := range adapter.sidecars() {
+			if err = s.delete(file); err != nil {
+				return false, err
+			}
+		}
+	}
+
 	return true, nil
 }
 
-func (adapter *LocalAdapter) MultipleDelete(fileList []string) (bool, error) {
+func (adapter *LocalAdapter) MultipleDelete(fileList []string) (_ bool, err error) {
+	defer adapter.log(OperationMultipleDelete, "", time.Now(), &err)
 	for _, file := range fileList {
-		if _, err := adapter.Delete(file); err != nil {
+		if _, err = adapter.Delete(file); err != nil {
 			return false, err
 		}
 	}
 	return true, nil
 }
 
-func (adapter *LocalAdapter) MkDir(dir string, mode os.FileMode) (bool, error) {
+func (adapter *LocalAdapter) MkDir(dir string, mode os.FileMode) (_ bool, err error) {
+	defer adapter.log(OperationMkDir, dir, time.Now(), &err)
 	if !adapter.HasDir(dir) {
-		if err := os.MkdirAll(adapter.absolutePath(dir), mode); err != nil {
+		if err = os.MkdirAll(adapter.absolutePath(dir), mode); err != nil {
 			return false, err
 		}
 	}
 	return true, nil
 }
 
-func (adapter *LocalAdapter) DeleteDir(dir string) (bool, error) {
+func (adapter *LocalAdapter) DeleteDir(dir string) (_ bool, err error) {
+	defer adapter.log(OperationDeleteDir, dir, time.Now(), &err)
 	if !adapter.HasDir(dir) {
 		return false, FileNotExists
 	}
 
-	if err := os.Remove(adapter.absolutePath(dir)); err != nil {
+	if err = os.Remove(adapter.absolutePath(dir)); err != nil {
 		return false, err
 	}
+	// 其下文件的旁路文件已随文件删除, 只清理空目录, 保留装饰器仍在使用的元数据
+	if dir = strings.Trim(dir, "/"); dir != "" {
+		removeEmptyDirs(adapter.absolutePath(sidecarsDir + "/" + dir))
+	}
 
 	return true, nil
 }
 
-func (adapter *LocalAdapter) List(dir string, iterable func(attribute storage.Attribute)) error {
+func (adapter *LocalAdapter) List(dir string, iterable func(attribute storage.Attribute)) (err error) {
+	defer adapter.log(OperationList, dir, time.Now(), &err)
 	if !adapter.HasDir(dir) {
 		return FileNotExists
 	}
 
-	err := filepath.Walk(adapter.absolutePath(dir), func(path string, info fs.FileInfo, err error) error {
+	err = filepath.WalkDir(adapter.absolutePath(dir), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
 		path = strings.Replace(path, adapter.config.BasePath, "", 1)
+		if entry.IsDir() && (strings.Trim(path, "/") == localVersionsDir || sidecarHidden(dir, path)) {
+			return filepath.SkipDir
+		}
+		if !entry.IsDir() && adapter.isSidecar(path) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// 遍历期间被删除的文件直接跳过
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
 		var attribute storage.Attribute
 		if info.IsDir() {
-			attribute = storage.NewDirectoryAttribute(info.Name(), path, "", info.ModTime().Unix())
+			attribute = storage.NewDirectoryAttribute(info.Name(), path, modeVisibility(info.Mode()), info.ModTime().Unix())
 		} else {
-			attribute = storage.NewFileAttribute(info.Name(), path, "", "", info.Size(), info.ModTime().Unix())
+			attribute = storage.NewFileAttribute(info.Name(), path, modeVisibility(info.Mode()), adapter.mimeType(path), info.Size(), info.ModTime().Unix())
 		}
 
 		iterable(attribute)
@@ -253,6 +406,336 @@ func (adapter *LocalAdapter) List(dir string, iterable func(attribute storage.At
 	return err
 }
 
+// removeEmptyDirs 自下而上删除目录及其下的空目录, 非空目录删除失败时忽略
+func removeEmptyDirs(root string) {
+	var dirs []string
+	_ = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+}
+
+// HealthCheck 检查根目录是否可写
+func (adapter *LocalAdapter) HealthCheck(ctx context.Context) HealthStatus {
+	start := time.Now()
+	err := runWithContext(ctx, func() error {
+		f, err := os.CreateTemp(adapter.config.BasePath, ".health-*")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = os.Remove(f.Name())
+		}()
+
+		if _, err = f.Write([]byte("ok")); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	})
+	return newHealthStatus(start, err)
+}
+
+// SetVisibility 通过文件权限位设置可见性, 公开时授予组及其他用户读取权限, 私有时仅所有者可访问
+func (adapter *LocalAdapter) SetVisibility(path string, visibility storage.Visibility) error {
+	if err := validVisibility(visibility); err != nil {
+		return err
+	}
+	info, err := os.Stat(adapter.absolutePath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return FileNotExists
+		}
+		return err
+	}
+
+	mode := info.Mode().Perm()
+	if visibility == storage.VisibilityPrivate {
+		mode &^= 0077
+	} else if info.IsDir() {
+		mode |= 0055
+	} else {
+		mode |= 0044
+	}
+	return os.Chmod(adapter.absolutePath(path), mode)
+}
+
+// Visibility 根据文件权限位获取可见性
+func (adapter *LocalAdapter) Visibility(path string) (storage.Visibility, error) {
+	info, err := os.Stat(adapter.absolutePath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", FileNotExists
+		}
+		return "", err
+	}
+	return modeVisibility(info.Mode()), nil
+}
+
+// modeVisibility 其他用户可读时为公开
+func modeVisibility(mode os.FileMode) storage.Visibility {
+	if mode.Perm()&0004 != 0 {
+		return storage.VisibilityPublic
+	}
+	return storage.VisibilityPrivate
+}
+
+// GetMetadata 读取旁路JSON文件中的自定义元数据
+func (adapter *LocalAdapter) GetMetadata(path string) (map[string]string, error) {
+	if !adapter.HasFile(path) {
+		return nil, FileNotExists
+	}
+	var metadata map[string]string
+	if err := adapter.metadata().load(path, &metadata); err != nil && !errors.Is(err, FileNotExists) {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// SetMetadata 将自定义元数据写入旁路JSON文件, 元数据为空时删除该文件
+func (adapter *LocalAdapter) SetMetadata(path string, metadata map[string]string, merge bool) error {
+	current, err := adapter.GetMetadata(path)
+	if err != nil {
+		return err
+	}
+	metadata, err = resolveMetadata(current, metadata, merge)
+	if err != nil {
+		return err
+	}
+	if len(metadata) == 0 {
+		return adapter.metadata().delete(path)
+	}
+	return adapter.metadata().save(path, metadata)
+}
+
+// PutTags 将标签写入旁路JSON文件, 标签为空时删除该文件
+func (adapter *LocalAdapter) PutTags(path string, tags map[string]string) error {
+	if err := validTags(tags); err != nil {
+		return err
+	}
+	if !adapter.HasFile(path) {
+		return FileNotExists
+	}
+	if len(tags) == 0 {
+		return adapter.tags().delete(path)
+	}
+	return adapter.tags().save(path, tags)
+}
+
+// GetTags 读取旁路JSON文件中的标签
+func (adapter *LocalAdapter) GetTags(path string) (map[string]string, error) {
+	if !adapter.HasFile(path) {
+		return nil, FileNotExists
+	}
+	tags := map[string]string{}
+	if err := adapter.tags().load(path, &tags); err != nil && !errors.Is(err, FileNotExists) {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// DeleteTags 删除标签旁路文件
+func (adapter *LocalAdapter) DeleteTags(path string) error {
+	if !adapter.HasFile(path) {
+		return FileNotExists
+	}
+	return adapter.tags().delete(path)
+}
+
+// metadata 自定义元数据旁路文件
+func (adapter *LocalAdapter) metadata() sidecar {
+	return sidecar{adapter: adapter, suffix: metadataSuffix, dir: sidecarsDir}
+}
+
+// tags 标签旁路文件
+func (adapter *LocalAdapter) tags() sidecar {
+	return sidecar{adapter: adapter, suffix: tagsSuffix, dir: sidecarsDir}
+}
+
+// checksums 校验值旁路文件
+func (adapter *LocalAdapter) checksums() sidecar {
+	return sidecar{adapter: adapter, suffix: checksumSuffix, dir: sidecarsDir}
+}
+
+// sidecars 随文件复制、删除的旁路文件
+func (adapter *LocalAdapter) sidecars() []sidecar {
+	return []sidecar{adapter.metadata(), adapter.tags(), adapter.checksums()}
+}
+
+// isSidecar 是否为旁路文件
+func (adapter *LocalAdapter) isSidecar(path string) bool {
+	for _, s := range adapter.sidecars() {
+		if s.is(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// localVersionsDir 本地存储历史版本目录, 按 .versions/文件路径/版本ID 保存
+const localVersionsDir = ".versions"
+
+// ListVersions 列出当前文件及 .versions 目录中的历史版本
+func (adapter *LocalAdapter) ListVersions(path string) ([]ObjectVersion, error) {
+	var versions []ObjectVersion
+	if info, err := os.Stat(adapter.absolutePath(path)); err == nil && !info.IsDir() {
+		versions = append(versions, ObjectVersion{
+			VersionID:    localVersionID(info),
+			IsLatest:     true,
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+	}
+
+	entries, err := os.ReadDir(adapter.versionDir(path))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.IsDir() {
+			continue
+		}
+		versions = append(versions, ObjectVersion{
+			VersionID:    entry.Name(),
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+	}
+
+	if len(versions) == 0 {
+		return nil, FileNotExists
+	}
+	return sortVersions(versions), nil
+}
+
+// ReadVersion 读取指定版本, 版本ID为当前文件时读取当前文件
+func (adapter *LocalAdapter) ReadVersion(path, versionID string) (io.ReadCloser, error) {
+	filename, err := adapter.versionFile(path, versionID)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(filename)
+}
+
+// RestoreVersion 将历史版本写回为当前文件, 开启版本控制时当前文件转为历史版本
+func (adapter *LocalAdapter) RestoreVersion(path, versionID string) error {
+	filename, err := adapter.versionFile(path, versionID)
+	if err != nil {
+		return err
+	}
+	if filename == adapter.absolutePath(path) {
+		return nil
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	_, err = adapter.Save(path, f, "")
+	return err
+}
+
+// DeleteVersion 永久删除指定版本, 删除当前文件时最近的历史版本成为当前文件
+func (adapter *LocalAdapter) DeleteVersion(path, versionID string) error {
+	filename, err := adapter.versionFile(path, versionID)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(filename); err != nil {
+		return err
+	}
+
+	if filename == adapter.absolutePath(path) {
+		for _, s := range adapter.sidecars() {
+			if err = s.delete(path); err != nil {
+				return err
+			}
+		}
+		versions, err := adapter.ListVersions(path)
+		if err == nil && len(versions) > 0 {
+			if err = os.Rename(filepath.Join(adapter.versionDir(path), versions[0].VersionID), filename); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 历史版本目录为空时一并删除
+	_ = os.Remove(adapter.versionDir(path))
+	return nil
+}
+
+// archive 开启版本控制时将当前文件移入历史版本目录
+// 返回的restore在后续写入失败时将历史版本还原为当前文件
+func (adapter *LocalAdapter) archive(file string) (restore func(), err error) {
+	restore = func() {}
+	if !adapter.config.Versioning || adapter.isSidecar(file) {
+		return restore, nil
+	}
+	info, err := os.Stat(adapter.absolutePath(file))
+	if err != nil || info.IsDir() {
+		return restore, nil
+	}
+
+	dir := adapter.versionDir(file)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return restore, err
+	}
+	versionID := localVersionID(info)
+	target := filepath.Join(dir, versionID)
+	for i := 1; ; i++ {
+		if _, err = os.Lstat(target); os.IsNotExist(err) {
+			break
+		}
+		target = filepath.Join(dir, versionID+"-"+strconv.Itoa(i))
+	}
+
+	if err = os.Rename(adapter.absolutePath(file), target); err != nil {
+		return restore, err
+	}
+	return func() {
+		_ = os.Rename(target, adapter.absolutePath(file))
+	}, nil
+}
+
+// versionDir 文件的历史版本目录
+func (adapter *LocalAdapter) versionDir(file string) string {
+	return adapter.config.BasePath + localVersionsDir + "/" + strings.TrimLeft(file, "/")
+}
+
+// versionFile 版本对应的文件路径
+func (adapter *LocalAdapter) versionFile(path, versionID string) (string, error) {
+	if versionID == "" || versionID != filepath.Base(versionID) || versionID == "." || versionID == ".." {
+		return "", errors.Errorf("invalid version id %q", versionID)
+	}
+	if info, err := os.Stat(adapter.absolutePath(path)); err == nil && !info.IsDir() && localVersionID(info) == versionID {
+		return adapter.absolutePath(path), nil
+	}
+
+	filename := filepath.Join(adapter.versionDir(path), versionID)
+	if _, err := os.Stat(filename); err != nil {
+		if os.IsNotExist(err) {
+			return "", FileNotExists
+		}
+		return "", err
+	}
+	return filename, nil
+}
+
+// localVersionID 以修改时间的纳秒时间戳作为版本ID, 归档时重命名不改变修改时间, 版本ID保持不变
+func localVersionID(info os.FileInfo) string {
+	return strconv.FormatInt(info.ModTime().UnixNano(), 10)
+}
+
 func (adapter *LocalAdapter) FullPath(path string) string {
 	var urlBuilder strings.Builder
 
@@ -279,3 +762,8 @@ func (adapter *LocalAdapter) OriginalPath(fullPath string) string {
 
 	return path
 }
+
+// log 记录操作日志
+func (adapter *LocalAdapter) log(operation, path string, start time.Time, err *error) {
+	logOperation(adapter.config.Logger, "local", operation, path, start, err)
+}
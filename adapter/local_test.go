@@ -0,0 +1,72 @@
+package adapter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func newTestLocalAdapter(t *testing.T) *LocalAdapter {
+	t.Helper()
+	return NewLocalAdapter(LocalConfig{BasePath: t.TempDir()}).(*LocalAdapter)
+}
+
+// saveWithSidecars 保存带自定义元数据、标签及校验值的文件
+func saveWithSidecars(t *testing.T, local *LocalAdapter, file string) Checksums {
+	t.Helper()
+	sums, err := local.SaveWithChecksum(file, bytes.NewReader([]byte("content")), "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = SetMetadata(local, file, map[string]string{"owner": "alice"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err = PutTags(local, file, map[string]string{"env": "prod"}); err != nil {
+		t.Fatal(err)
+	}
+	return sums
+}
+
+// assertSidecars 校验文件的自定义元数据、标签及校验值
+func assertSidecars(t *testing.T, local *LocalAdapter, file string, sums Checksums) {
+	t.Helper()
+	if metadata, err := GetMetadata(local, file); err != nil || metadata["owner"] != "alice" {
+		t.Errorf("%s metadata: got %v, %v", file, metadata, err)
+	}
+	if tags, err := GetTags(local, file); err != nil || tags["env"] != "prod" {
+		t.Errorf("%s tags: got %v, %v", file, tags, err)
+	}
+	var got Checksums
+	if err := local.checksums().load(file, &got); err != nil || got != sums {
+		t.Errorf("%s checksums: got %+v, %v, want %+v", file, got, err, sums)
+	}
+}
+
+func TestLocalAdapterCopyKeepsSidecars(t *testing.T) {
+	local := newTestLocalAdapter(t)
+	sums := saveWithSidecars(t, local, "a.txt")
+	if _, err := local.MkDir("fresh/dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := local.Copy("a.txt", "fresh/dir/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	assertSidecars(t, local, "a.txt", sums)
+	assertSidecars(t, local, "fresh/dir/a.txt", sums)
+}
+
+func TestLocalAdapterMoveKeepsSidecars(t *testing.T) {
+	local := newTestLocalAdapter(t)
+	sums := saveWithSidecars(t, local, "a.txt")
+	if _, err := local.MkDir("fresh/dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := local.Move("fresh/dir/a.txt", "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	assertSidecars(t, local, "fresh/dir/a.txt", sums)
+	if metadata, err := GetMetadata(local, "a.txt"); err == nil {
+		t.Errorf("source metadata still present: %v", metadata)
+	}
+}
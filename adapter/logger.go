@@ -0,0 +1,61 @@
+package adapter
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// discardHandler 丢弃所有日志, 适配器未注入Logger时使用
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// defaultLogger 未注入Logger时返回静默Logger
+func defaultLogger(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.New(discardHandler{})
+	}
+	return logger
+}
+
+// logOperation 记录存储操作日志
+// 成功及文件不存在记录为Debug, 临时性错误记录为Warn, 其余错误记录为Error
+// 先确定级别并检查Logger是否启用, 未启用时不归类错误、不构造日志属性
+func logOperation(logger *slog.Logger, backend, operation, path string, start time.Time, err *error) {
+	ctx := context.Background()
+	failed := err != nil && *err != nil
+	if !logger.Enabled(ctx, slog.LevelError) || (!failed && !logger.Enabled(ctx, slog.LevelDebug)) {
+		return
+	}
+
+	level := slog.LevelDebug
+	var kind ErrorKind
+	if failed {
+		kind = ClassifyError(*err)
+		switch {
+		case kind == ErrorKindNotFound:
+		case kind.retryable():
+			level = slog.LevelWarn
+		default:
+			level = slog.LevelError
+		}
+		if !logger.Enabled(ctx, level) {
+			return
+		}
+	}
+
+	attrs := []slog.Attr{
+		slog.String("backend", backend),
+		slog.String("operation", operation),
+		slog.String("path", path),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if failed {
+		attrs = append(attrs, slog.String("error", (*err).Error()), slog.String("error_kind", string(kind)))
+	}
+	logger.LogAttrs(ctx, level, "storage operation", attrs...)
+}
@@ -0,0 +1,132 @@
+package adapter
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var MetadataUnsupported = errors.New("metadata is not supported by the adapter")
+
+// metadataSuffix 本地存储自定义元数据文件后缀
+const metadataSuffix = ".fsmeta"
+
+// MetadataAdapter 支持读写文件自定义元数据的存储适配器
+// 元数据键统一为小写, 云存储以 x-*-meta-* 请求头保存, 本地存储以旁路JSON文件保存
+type MetadataAdapter interface {
+	// GetMetadata 获取文件自定义元数据
+	// @param path string 文件路径
+	GetMetadata(path string) (map[string]string, error)
+
+	// SetMetadata 设置文件自定义元数据
+	// @param path string 文件路径
+	// @param metadata map[string]string 元数据
+	// @param merge bool 是否与已有元数据合并, 为false时整体替换; 合并时值为空的键将被删除
+	SetMetadata(path string, metadata map[string]string, merge bool) error
+}
+
+// GetMetadata 获取文件自定义元数据, 沿装饰器链查找MetadataAdapter
+// @param adapter Adapter 存储适配器
+// @param path string 文件路径
+func GetMetadata(adapter Adapter, path string) (map[string]string, error) {
+	metadataAdapter, ok := As[MetadataAdapter](adapter)
+	if !ok {
+		return nil, MetadataUnsupported
+	}
+	return metadataAdapter.GetMetadata(path)
+}
+
+// SetMetadata 设置文件自定义元数据, 沿装饰器链查找MetadataAdapter
+// @param adapter Adapter 存储适配器
+// @param path string 文件路径
+// @param metadata map[string]string 元数据
+// @param merge bool 是否与已有元数据合并
+func SetMetadata(adapter Adapter, path string, metadata map[string]string, merge bool) error {
+	metadataAdapter, ok := As[MetadataAdapter](adapter)
+	if !ok {
+		return MetadataUnsupported
+	}
+	return metadataAdapter.SetMetadata(path, metadata, merge)
+}
+
+// resolveMetadata 校验并计算写入的元数据
+// @param current map[string]string 已有元数据
+// @param metadata map[string]string 新元数据
+// @param merge bool 是否合并
+func resolveMetadata(current, metadata map[string]string, merge bool) (map[string]string, error) {
+	result := make(map[string]string, len(current)+len(metadata))
+	if merge {
+		for key, value := range current {
+			result[strings.ToLower(key)] = value
+		}
+	}
+	for key, value := range metadata {
+		key = strings.ToLower(key)
+		if !validMetadataKey(key) {
+			return nil, errors.Errorf("invalid metadata key %q", key)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, errors.Errorf("invalid metadata value for key %q", key)
+		}
+		if value == "" {
+			delete(result, key)
+			continue
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// validMetadataKey 元数据键仅允许小写字母、数字、- 与 _
+func validMetadataKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// userMetadata 从响应头中提取自定义元数据
+// @param header http.Header 响应头
+// @param prefix string 厂商请求头前缀, 如 X-Oss-
+func userMetadata(header http.Header, prefix string) map[string]string {
+	metaPrefix := strings.ToLower(prefix) + "meta-"
+	var metadata map[string]string
+	for key, values := range header {
+		name, ok := strings.CutPrefix(strings.ToLower(key), metaPrefix)
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[name] = values[0]
+	}
+	return metadata
+}
+
+// contentHeaderNames 替换元数据复制时需保留的标准请求头
+var contentHeaderNames = []string{"Content-Type", "Cache-Control", "Content-Disposition", "Content-Encoding", "Content-Language", "Expires"}
+
+// GetMetadata 获取挂载点内文件自定义元数据
+func (manager *MountManager) GetMetadata(path string) (map[string]string, error) {
+	mp, err := manager.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return GetMetadata(mp.adapter, mp.path)
+}
+
+// SetMetadata 设置挂载点内文件自定义元数据
+func (manager *MountManager) SetMetadata(path string, metadata map[string]string, merge bool) error {
+	mp, err := manager.resolve(path)
+	if err != nil {
+		return err
+	}
+	return SetMetadata(mp.adapter, mp.path, metadata, merge)
+}
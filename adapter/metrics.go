@@ -0,0 +1,178 @@
+package adapter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets 默认耗时直方图分桶(秒)
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// MetricsObserver 指标观察者, 以Prometheus文本格式导出
+//
+//	filesystem_operation_duration_seconds{backend,operation}  操作耗时直方图
+//	filesystem_operation_bytes_total{backend,operation}       读写字节数
+//	filesystem_operation_errors_total{backend,operation,kind} 按类别统计的错误数
+type MetricsObserver struct {
+	mu         sync.Mutex
+	buckets    []float64
+	histograms map[metricKey]*histogram
+	bytes      map[metricKey]int64
+	errors     map[metricKey]int64
+}
+
+type metricKey struct {
+	backend   string
+	operation string
+	kind      ErrorKind
+}
+
+type histogram struct {
+	counts []uint64 // 与buckets一一对应, 非累计
+	count  uint64
+	sum    float64
+}
+
+// NewMetricsObserver 创建指标观察者
+// @param buckets []float64 耗时分桶(秒), 为空时使用DefaultLatencyBuckets
+func NewMetricsObserver(buckets ...float64) *MetricsObserver {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &MetricsObserver{
+		buckets:    buckets,
+		histograms: make(map[metricKey]*histogram),
+		bytes:      make(map[metricKey]int64),
+		errors:     make(map[metricKey]int64),
+	}
+}
+
+func (observer *MetricsObserver) StartSpan(backend, operation, path string) Span {
+	return &metricsSpan{
+		observer:  observer,
+		backend:   backend,
+		operation: operation,
+		start:     time.Now(),
+	}
+}
+
+type metricsSpan struct {
+	observer  *MetricsObserver
+	backend   string
+	operation string
+	start     time.Time
+}
+
+func (span *metricsSpan) End(bytes int64, err error) {
+	span.observer.record(span.backend, span.operation, time.Since(span.start), bytes, err)
+}
+
+func (observer *MetricsObserver) record(backend, operation string, duration time.Duration, bytes int64, err error) {
+	key := metricKey{backend: backend, operation: operation}
+	seconds := duration.Seconds()
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+
+	h, ok := observer.histograms[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(observer.buckets))}
+		observer.histograms[key] = h
+	}
+	h.count++
+	h.sum += seconds
+	if i := sort.SearchFloat64s(observer.buckets, seconds); i < len(observer.buckets) {
+		h.counts[i]++
+	}
+
+	if bytes > 0 {
+		observer.bytes[key] += bytes
+	}
+	if err != nil {
+		observer.errors[metricKey{backend: backend, operation: operation, kind: ClassifyError(err)}]++
+	}
+}
+
+// WritePrometheus 以Prometheus文本格式输出指标
+func (observer *MetricsObserver) WritePrometheus(w io.Writer) error {
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+
+	_, _ = fmt.Fprintln(bw, "# HELP filesystem_operation_duration_seconds Storage operation latency in seconds.")
+	_, _ = fmt.Fprintln(bw, "# TYPE filesystem_operation_duration_seconds histogram")
+	for _, key := range sortedMetricKeys(observer.histograms) {
+		h := observer.histograms[key]
+		labels := metricLabels(key)
+		var cumulative uint64
+		for i, bound := range observer.buckets {
+			cumulative += h.counts[i]
+			_, _ = fmt.Fprintf(bw, "filesystem_operation_duration_seconds_bucket{%s,le=%q} %d\n", labels, formatFloat(bound), cumulative)
+		}
+		_, _ = fmt.Fprintf(bw, "filesystem_operation_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		_, _ = fmt.Fprintf(bw, "filesystem_operation_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		_, _ = fmt.Fprintf(bw, "filesystem_operation_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	_, _ = fmt.Fprintln(bw, "# HELP filesystem_operation_bytes_total Bytes transferred by storage read and save operations.")
+	_, _ = fmt.Fprintln(bw, "# TYPE filesystem_operation_bytes_total counter")
+	for _, key := range sortedMetricKeys(observer.bytes) {
+		_, _ = fmt.Fprintf(bw, "filesystem_operation_bytes_total{%s} %d\n", metricLabels(key), observer.bytes[key])
+	}
+
+	_, _ = fmt.Fprintln(bw, "# HELP filesystem_operation_errors_total Failed storage operations by error kind.")
+	_, _ = fmt.Fprintln(bw, "# TYPE filesystem_operation_errors_total counter")
+	for _, key := range sortedMetricKeys(observer.errors) {
+		_, _ = fmt.Fprintf(bw, "filesystem_operation_errors_total{%s,kind=\"%s\"} %d\n", metricLabels(key), escapeLabel(string(key.kind)), observer.errors[key])
+	}
+
+	return bw.Flush()
+}
+
+// ServeHTTP 作为Prometheus抓取端点
+func (observer *MetricsObserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = observer.WritePrometheus(w)
+}
+
+func sortedMetricKeys[V any](m map[metricKey]V) []metricKey {
+	keys := make([]metricKey, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].backend != keys[j].backend {
+			return keys[i].backend < keys[j].backend
+		}
+		if keys[i].operation != keys[j].operation {
+			return keys[i].operation < keys[j].operation
+		}
+		return keys[i].kind < keys[j].kind
+	})
+	return keys
+}
+
+func metricLabels(key metricKey) string {
+	return fmt.Sprintf("backend=\"%s\",operation=\"%s\"", escapeLabel(key.backend), escapeLabel(key.operation))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
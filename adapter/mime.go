@@ -0,0 +1,307 @@
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLength 识别文件类型读取的头部字节数
+const sniffLength = 512
+
+// MimeDetector 文件类型识别
+type MimeDetector interface {
+	// Detect 识别文件类型
+	// @param path string 文件路径, 用于扩展名识别
+	// @param header []byte 文件头部内容, 为空时仅按扩展名识别
+	Detect(path string, header []byte) string
+}
+
+// MimeDetectorFunc 函数形式的文件类型识别
+type MimeDetectorFunc func(path string, header []byte) string
+
+func (fn MimeDetectorFunc) Detect(path string, header []byte) string {
+	return fn(path, header)
+}
+
+// DefaultMimeDetector 默认文件类型识别
+var DefaultMimeDetector MimeDetector = NewMimeDetector(nil)
+
+// mimeDetectorAdapter 配置了文件类型识别的存储适配器
+type mimeDetectorAdapter interface {
+	mimeDetector() MimeDetector
+}
+
+// resolveMimeDetector 沿装饰器链查找底层适配器的文件类型识别, 未找到时返回 DefaultMimeDetector
+func resolveMimeDetector(adapter Adapter) MimeDetector {
+	if detector, ok := As[mimeDetectorAdapter](adapter); ok {
+		return detector.mimeDetector()
+	}
+	return DefaultMimeDetector
+}
+
+// octetStream 未知类型
+const octetStream = "application/octet-stream"
+
+// extensionTypes 常见扩展名对应的文件类型, 不依赖系统 mime.types
+var extensionTypes = map[string]string{
+	".txt":    "text/plain; charset=utf-8",
+	".log":    "text/plain; charset=utf-8",
+	".md":     "text/markdown; charset=utf-8",
+	".csv":    "text/csv; charset=utf-8",
+	".html":   "text/html; charset=utf-8",
+	".htm":    "text/html; charset=utf-8",
+	".css":    "text/css; charset=utf-8",
+	".js":     "text/javascript; charset=utf-8",
+	".json":   "application/json",
+	".jsonl":  "application/x-ndjson",
+	".ndjson": "application/x-ndjson",
+	".xml":    "application/xml",
+	".yaml":   "application/x-yaml",
+	".yml":    "application/x-yaml",
+	".sql":    "application/sql",
+	".pdf":    "application/pdf",
+	".rtf":    "application/rtf",
+	".doc":    "application/msword",
+	".xls":    "application/vnd.ms-excel",
+	".ppt":    "application/vnd.ms-powerpoint",
+	".docx":   "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx":   "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":    "application/vnd.oasis.opendocument.text",
+	".ods":    "application/vnd.oasis.opendocument.spreadsheet",
+	".odp":    "application/vnd.oasis.opendocument.presentation",
+	".epub":   "application/epub+zip",
+	".png":    "image/png",
+	".jpg":    "image/jpeg",
+	".jpeg":   "image/jpeg",
+	".gif":    "image/gif",
+	".webp":   "image/webp",
+	".bmp":    "image/bmp",
+	".ico":    "image/x-icon",
+	".svg":    "image/svg+xml",
+	".heic":   "image/heic",
+	".avif":   "image/avif",
+	".mp3":    "audio/mpeg",
+	".wav":    "audio/wav",
+	".m4a":    "audio/mp4",
+	".ogg":    "audio/ogg",
+	".flac":   "audio/flac",
+	".mp4":    "video/mp4",
+	".m4v":    "video/mp4",
+	".mov":    "video/quicktime",
+	".mkv":    "video/x-matroska",
+	".webm":   "video/webm",
+	".avi":    "video/x-msvideo",
+	".flv":    "video/x-flv",
+	".wmv":    "video/x-ms-wmv",
+	".3gp":    "video/3gpp",
+	".mpeg":   "video/mpeg",
+	".mpg":    "video/mpeg",
+	".zip":    "application/zip",
+	".7z":     "application/x-7z-compressed",
+	".rar":    "application/vnd.rar",
+	".gz":     "application/gzip",
+	".tgz":    "application/gzip",
+	".bz2":    "application/x-bzip2",
+	".xz":     "application/x-xz",
+	".zst":    "application/zstd",
+	".tar":    "application/x-tar",
+	".jar":    "application/java-archive",
+	".apk":    "application/vnd.android.package-archive",
+}
+
+// genericTypes 魔数只能识别到容器格式或文本的类型, 可由扩展名细化
+var genericTypes = map[string]bool{
+	"":                             true,
+	octetStream:                    true,
+	"application/zip":              true,
+	"application/x-ole-storage":    true,
+	"text/plain; charset=utf-8":    true,
+	"text/plain; charset=utf-16be": true,
+	"text/plain; charset=utf-16le": true,
+	"text/xml; charset=utf-8":      true,
+	"application/x-gzip":           true,
+	"application/gzip":             true,
+}
+
+// MimeTypeDetector 组合扩展名与魔数的文件类型识别
+// 魔数识别出具体类型时以魔数为准; 只识别到容器格式(zip, OLE)或纯文本时按扩展名细化,
+// 如 zip + .docx 识别为Word文档, 文本 + .json 识别为JSON
+type MimeTypeDetector struct {
+	extensions map[string]string
+}
+
+// NewMimeDetector 创建文件类型识别
+// @param extensions map[string]string 自定义扩展名映射, 如 ".log": "text/plain", 优先于内置映射
+func NewMimeDetector(extensions map[string]string) *MimeTypeDetector {
+	detector := &MimeTypeDetector{extensions: make(map[string]string, len(extensions))}
+	for ext, mimeType := range extensions {
+		detector.extensions[strings.ToLower(ext)] = mimeType
+	}
+	return detector
+}
+
+func (detector *MimeTypeDetector) Detect(path string, header []byte) string {
+	byExtension := detector.byExtension(path)
+	if len(header) == 0 {
+		if byExtension == "" {
+			return octetStream
+		}
+		return byExtension
+	}
+
+	byMagic := sniff(header)
+	if genericTypes[byMagic] && byExtension != "" {
+		return byExtension
+	}
+	return byMagic
+}
+
+// byExtension 按扩展名识别
+func (detector *MimeTypeDetector) byExtension(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == "" {
+		return ""
+	}
+	if mimeType, ok := detector.extensions[ext]; ok {
+		return mimeType
+	}
+	if mimeType, ok := extensionTypes[ext]; ok {
+		return mimeType
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// sniff 按魔数识别, 补充 http.DetectContentType 无法识别的视频、压缩包与Office格式
+func sniff(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}):
+		return "application/x-ole-storage"
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		return sniffZip(header)
+	case len(header) >= 12 && bytes.Equal(header[4:8], []byte("ftyp")):
+		return sniffFtyp(header[8:12])
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		if bytes.Contains(header, []byte("webm")) {
+			return "video/webm"
+		}
+		return "video/x-matroska"
+	case len(header) >= 12 && bytes.HasPrefix(header, []byte("RIFF")) && bytes.Equal(header[8:12], []byte("AVI ")):
+		return "video/x-msvideo"
+	case bytes.HasPrefix(header, []byte("FLV\x01")):
+		return "video/x-flv"
+	case bytes.HasPrefix(header, []byte{0x30, 0x26, 0xB2, 0x75, 0x8E, 0x66, 0xCF, 0x11}):
+		return "video/x-ms-asf"
+	case bytes.HasPrefix(header, []byte{0x00, 0x00, 0x01, 0xBA}):
+		return "video/mpeg"
+	case len(header) > 188 && header[0] == 0x47 && header[188] == 0x47:
+		return "video/mp2t"
+	case bytes.HasPrefix(header, []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}):
+		return "application/x-7z-compressed"
+	case bytes.HasPrefix(header, []byte("Rar!\x1A\x07")):
+		return "application/vnd.rar"
+	case bytes.HasPrefix(header, []byte("BZh")):
+		return "application/x-bzip2"
+	case bytes.HasPrefix(header, []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}):
+		return "application/x-xz"
+	case bytes.HasPrefix(header, []byte{0x28, 0xB5, 0x2F, 0xFD}):
+		return "application/zstd"
+	case len(header) >= 262 && bytes.Equal(header[257:262], []byte("ustar")):
+		return "application/x-tar"
+	case bytes.HasPrefix(header, []byte(`{\rtf`)):
+		return "application/rtf"
+	}
+	return http.DetectContentType(header)
+}
+
+// sniffZip 识别基于zip的格式: OpenDocument/EPUB 的首个条目为未压缩的 mimetype, OOXML 按目录名识别
+func sniffZip(header []byte) string {
+	const nameOffset = 30
+	if len(header) > nameOffset {
+		nameLength := int(header[26]) | int(header[27])<<8
+		extraLength := int(header[28]) | int(header[29])<<8
+		if end := nameOffset + nameLength; end <= len(header) && string(header[nameOffset:end]) == "mimetype" {
+			content := header[min(end+extraLength, len(header)):]
+			for _, mimeType := range []string{
+				"application/vnd.oasis.opendocument.text",
+				"application/vnd.oasis.opendocument.spreadsheet",
+				"application/vnd.oasis.opendocument.presentation",
+				"application/epub+zip",
+			} {
+				if bytes.HasPrefix(content, []byte(mimeType)) {
+					return mimeType
+				}
+			}
+		}
+	}
+
+	switch {
+	case bytes.Contains(header, []byte("word/")):
+		return extensionTypes[".docx"]
+	case bytes.Contains(header, []byte("xl/")):
+		return extensionTypes[".xlsx"]
+	case bytes.Contains(header, []byte("ppt/")):
+		return extensionTypes[".pptx"]
+	case bytes.Contains(header, []byte("AndroidManifest.xml")):
+		return extensionTypes[".apk"]
+	case bytes.Contains(header, []byte("META-INF/")):
+		return extensionTypes[".jar"]
+	}
+	return "application/zip"
+}
+
+// sniffFtyp 按ISO媒体文件的主品牌识别
+func sniffFtyp(brand []byte) string {
+	switch string(brand) {
+	case "qt  ":
+		return "video/quicktime"
+	case "M4A ", "M4B ":
+		return "audio/mp4"
+	case "heic", "heix", "mif1", "msf1":
+		return "image/heic"
+	case "avif", "avis":
+		return "image/avif"
+	}
+	if bytes.HasPrefix(brand, []byte("3gp")) || bytes.HasPrefix(brand, []byte("3g2")) {
+		return "video/3gpp"
+	}
+	return "video/mp4"
+}
+
+// storedMimeType 优先使用存储的文件类型, 未记录或为未知类型时按扩展名识别
+func storedMimeType(detector MimeDetector, path, stored string) string {
+	if stored != "" && stored != octetStream {
+		return stored
+	}
+	return detector.Detect(path, nil)
+}
+
+// detectReader 读取头部识别文件类型, 返回包含完整内容的读取器
+// 可Seek的读取器识别后回到原位置并原样返回, 以保留长度等信息
+func detectReader(detector MimeDetector, path string, reader io.Reader) (string, io.Reader, error) {
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			header := make([]byte, sniffLength)
+			n, err := io.ReadFull(seeker, header)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return "", nil, err
+			}
+			if _, err = seeker.Seek(offset, io.SeekStart); err != nil {
+				return "", nil, err
+			}
+			return detector.Detect(path, header[:n]), reader, nil
+		}
+	}
+
+	buffered := bufio.NewReaderSize(reader, sniffLength)
+	header, err := buffered.Peek(sniffLength)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", nil, err
+	}
+	return detector.Detect(path, header), buffered, nil
+}
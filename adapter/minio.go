@@ -3,134 +3,346 @@ package adapter
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dysodeng/filesystem/storage"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/minio/minio-go/v7/pkg/tags"
+	"github.com/pkg/errors"
 )
 
 // MinioAdapter Minio存储适配器,兼容AWS S3
 type MinioAdapter struct {
-	client *minio.Client
-	config MinioConfig
+	client   *minio.Client
+	config   MinioConfig
+	policyMu *sync.Mutex // 串行化对存储桶策略的读改写, 与服务端加密副本共享
 }
 
 type MinioConfig struct {
-	AccessKey  string
-	SecretKey  string
-	EndPoint   string
-	BucketName string
-	UseSSL     bool // 是否使用https
-	IsPrivate  bool // 是否私有访问权限
-	IsAwsS3    bool // 是否为AWS S3存储
-}
-
-func NewMinioAdapter(config MinioConfig) Adapter {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Println(err)
-		}
-	}()
+	AccessKey         string
+	SecretKey         string
+	EndPoint          string
+	BucketName        string
+	UseSSL            bool                 // 是否使用https
+	IsPrivate         bool                 // 是否私有访问权限
+	ResolveVisibility bool                 // Info与List查询存储桶策略获取可见性, 每次调用多一次请求; 默认按IsPrivate返回
+	IsAwsS3           bool                 // 是否为AWS S3存储
+	Probe             bool                 // 初始化时检查存储桶是否存在及凭证是否有效
+	Encryption        ServerSideEncryption // 服务端加密, 默认不加密
+	Logger            *slog.Logger         // 日志, 默认不输出
+	MimeDetector      MimeDetector         // 文件类型识别, 默认DefaultMimeDetector
+}
+
+// validate 校验配置
+func (config MinioConfig) validate() error {
+	switch {
+	case config.AccessKey == "":
+		return &ConfigError{Driver: "minio", Field: "AccessKey", Reason: "is required"}
+	case config.SecretKey == "":
+		return &ConfigError{Driver: "minio", Field: "SecretKey", Reason: "is required"}
+	case config.EndPoint == "":
+		return &ConfigError{Driver: "minio", Field: "EndPoint", Reason: "is required"}
+	case strings.Contains(config.EndPoint, "://"):
+		return &ConfigError{Driver: "minio", Field: "EndPoint", Reason: "must not contain scheme, use UseSSL instead"}
+	case config.BucketName == "":
+		return &ConfigError{Driver: "minio", Field: "BucketName", Reason: "is required"}
+	}
+	return config.Encryption.validate("minio")
+}
+
+func init() {
+	Register("minio", newMinioAdapterFromConfig, dsnParser("access_key", "secret_key", "endpoint", "bucket"))
+}
 
-	minioAdapter := new(MinioAdapter)
+// newMinioAdapterFromConfig 根据驱动配置创建Minio存储适配器
+func newMinioAdapterFromConfig(config map[string]any) (Adapter, error) {
+	reader := newConfigReader("minio", config)
+	minioConfig := MinioConfig{
+		AccessKey:         reader.string("access_key", true),
+		SecretKey:         reader.string("secret_key", true),
+		EndPoint:          reader.string("endpoint", true),
+		BucketName:        reader.string("bucket", true),
+		UseSSL:            reader.bool("ssl"),
+		IsPrivate:         reader.bool("private"),
+		ResolveVisibility: reader.bool("resolve_visibility"),
+		IsAwsS3:           reader.bool("aws_s3"),
+		Probe:             reader.bool("probe"),
+		Encryption:        reader.encryption(),
+	}
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	return NewMinioAdapter(minioConfig)
+}
+
+func NewMinioAdapter(config MinioConfig) (Adapter, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	config.Logger = defaultLogger(config.Logger)
+	if config.MimeDetector == nil {
+		config.MimeDetector = DefaultMimeDetector
+	}
 
 	client, err := minio.New(config.EndPoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
 		Secure: config.UseSSL,
 	})
 	if err != nil {
-		panic("minio server connect error:" + err.Error())
+		return nil, errors.Wrap(err, "minio server connect error")
 	}
 
-	minioAdapter.client = client
-	minioAdapter.config = config
+	if config.Probe {
+		ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+		defer cancel()
 
-	return minioAdapter
+		exists, err := client.BucketExists(ctx, config.BucketName)
+		if err != nil {
+			return nil, errors.Wrap(err, "minio probe error")
+		}
+		if !exists {
+			return nil, errors.Wrap(BucketNotExists, config.BucketName)
+		}
+	}
+
+	return &MinioAdapter{
+		client:   client,
+		config:   config,
+		policyMu: &sync.Mutex{},
+	}, nil
 }
 
-func (adapter *MinioAdapter) Info(file string) (storage.Attribute, error) {
-	info, err := adapter.client.StatObject(context.Background(), adapter.config.BucketName, file, minio.StatObjectOptions{})
+// mimeDetector 文件类型识别, 供装饰器沿用
+func (adapter *MinioAdapter) mimeDetector() MimeDetector {
+	return adapter.config.MimeDetector
+}
+
+func (adapter *MinioAdapter) Info(file string) (_ storage.Attribute, err error) {
+	defer adapter.log(OperationInfo, file, time.Now(), &err)
+	customerKey, err := adapter.customerKey()
 	if err != nil {
-		return nil, FileNotExists
+		return nil, err
+	}
+	info, err := adapter.client.StatObject(context.Background(), adapter.config.BucketName, file, minio.StatObjectOptions{
+		ServerSideEncryption: customerKey,
+		Checksum:             true,
+	})
+	if err != nil {
+		return nil, notExists(err)
 	}
 
 	names := strings.Split(strings.TrimRight(info.Key, "/"), "/")
-
-	return storage.NewFileAttribute(names[len(names)-1], info.Key, "", info.ContentType, info.Size, info.LastModified.Unix()), nil
+	visibility := adapter.visibility(adapter.policy(), info.Key)
+	mimeType := storedMimeType(adapter.config.MimeDetector, info.Key, info.ContentType)
+	attribute := storage.NewFileAttribute(names[len(names)-1], info.Key, visibility, mimeType, info.Size, info.LastModified.Unix())
+
+	return withObjectHeader(attribute, info.Metadata, "X-Amz-").
+		WithETag(info.ETag).
+		WithChecksums("", "", info.ChecksumSHA256).
+		WithStorageClass(info.StorageClass).
+		WithVersionID(info.VersionID), nil
 }
 
 func (adapter *MinioAdapter) HasFile(file string) bool {
-	_, err := adapter.Info(file)
+	customerKey, err := adapter.customerKey()
 	if err != nil {
 		return false
 	}
-	return true
+	_, err = adapter.client.StatObject(context.Background(), adapter.config.BucketName, file, minio.StatObjectOptions{
+		ServerSideEncryption: customerKey,
+	})
+	return err == nil
 }
 
 func (adapter *MinioAdapter) HasDir(file string) bool {
 	return true
 }
 
-func (adapter *MinioAdapter) Read(file string) (io.ReadCloser, error) {
-	object, err := adapter.client.GetObject(context.Background(), adapter.config.BucketName, file, minio.GetObjectOptions{})
+func (adapter *MinioAdapter) Read(file string) (_ io.ReadCloser, err error) {
+	defer adapter.log(OperationRead, file, time.Now(), &err)
+	customerKey, err := adapter.customerKey()
 	if err != nil {
-		return nil, FileNotExists
+		return nil, err
+	}
+	object, err := adapter.client.GetObject(context.Background(), adapter.config.BucketName, file, minio.GetObjectOptions{
+		ServerSideEncryption: customerKey,
+	})
+	if err != nil {
+		return nil, notExists(err)
+	}
+	// GetObject 延迟请求, 提前发起请求以便返回错误
+	if _, err = object.Stat(); err != nil {
+		_ = object.Close()
+		return nil, notExists(err)
 	}
 	return object, nil
 }
 
-func (adapter *MinioAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (bool, error) {
+func (adapter *MinioAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (_ bool, err error) {
+	defer adapter.log(OperationSave, dstFile, time.Now(), &err)
+	sse, err := adapter.sse()
+	if err != nil {
+		return false, err
+	}
 	content, err := io.ReadAll(srcFile)
 	if err != nil {
 		return false, err
 	}
-	_, err = adapter.client.PutObject(
+	if mimeType == "" {
+		mimeType = adapter.config.MimeDetector.Detect(dstFile, content[:min(len(content), sniffLength)])
+	}
+	if _, err = adapter.put(dstFile, content, mimeType, sse, false, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SaveWithChecksum 保存文件并校验上传内容, 上传时携带Content-MD5, 上传后比对ETag中的MD5
+func (adapter *MinioAdapter) SaveWithChecksum(dstFile string, srcFile io.Reader, mimeType string) (_ Checksums, err error) {
+	defer adapter.log(OperationSave, dstFile, time.Now(), &err)
+	sse, err := adapter.sse()
+	if err != nil {
+		return Checksums{}, err
+	}
+	content, err := io.ReadAll(srcFile)
+	if err != nil {
+		return Checksums{}, err
+	}
+	if mimeType == "" {
+		mimeType = adapter.config.MimeDetector.Detect(dstFile, content[:min(len(content), sniffLength)])
+	}
+	hashes := newChecksumHashes()
+	_, _ = hashes.Write(content)
+	reported, err := adapter.put(dstFile, content, mimeType, sse, true, nil)
+	if err != nil {
+		return Checksums{}, err
+	}
+	return verifyUpload(adapter, dstFile, hashes.sums(), reported)
+}
+
+// SaveEncoded 保存已压缩的内容, 设置Content-Encoding并在自定义元数据中记录压缩前的大小
+func (adapter *MinioAdapter) SaveEncoded(dstFile string, srcFile io.Reader, mimeType string, encoding ContentEncoding, size int64) (err error) {
+	defer adapter.log(OperationSave, dstFile, time.Now(), &err)
+	sse, err := adapter.sse()
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(srcFile)
+	if err != nil {
+		return err
+	}
+	_, err = adapter.put(dstFile, content, mimeType, sse, false, &encodedContent{encoding: encoding, size: size})
+	return err
+}
+
+// put 上传文件, 返回服务端记录的校验值
+func (adapter *MinioAdapter) put(dstFile string, content []byte, mimeType string, sse encrypt.ServerSide, sendMD5 bool, encoded *encodedContent) (Checksums, error) {
+	options := minio.PutObjectOptions{ContentType: mimeType, ServerSideEncryption: sse, SendContentMd5: sendMD5}
+	if encoded != nil {
+		options.ContentEncoding = string(encoded.encoding)
+		options.UserMetadata = map[string]string{uncompressedSizeMetadata: encoded.sizeValue()}
+	}
+	info, err := adapter.client.PutObject(
 		context.Background(),
 		adapter.config.BucketName,
 		dstFile,
 		bytes.NewReader(content),
 		int64(len(content)),
-		minio.PutObjectOptions{ContentType: mimeType},
+		options,
 	)
 	if err != nil {
-		return false, err
+		return Checksums{}, err
 	}
-	return true, nil
+	reported := Checksums{SHA256: info.ChecksumSHA256}
+	if adapter.config.Encryption.etagIsContentMD5() {
+		reported.MD5 = etagContentMD5(info.ETag)
+	}
+	return reported, nil
 }
 
-func (adapter *MinioAdapter) Cover(sourceImagePath, coverImagePath string, width, height uint) error {
+func (adapter *MinioAdapter) Cover(sourceImagePath, coverImagePath string, width, height uint) (err error) {
+	defer adapter.log(OperationCover, sourceImagePath, time.Now(), &err)
 	// TODO
 	return nil
 }
 
-func (adapter *MinioAdapter) Copy(srcFile, dstFile string) (bool, error) {
-	src := minio.CopySrcOptions{
-		Bucket: adapter.config.BucketName,
-		Object: srcFile,
+func (adapter *MinioAdapter) Copy(srcFile, dstFile string) (_ bool, err error) {
+	defer adapter.log(OperationCopy, srcFile, time.Now(), &err)
+	if err = adapter.copyObject(adapter, srcFile, dstFile); err != nil {
+		return false, err
 	}
+	return true, nil
+}
 
-	dst := minio.CopyDestOptions{
-		Bucket: adapter.config.BucketName,
-		Object: dstFile,
+// CopyFrom 从同一服务、同一账号的其他存储桶服务端复制文件
+func (adapter *MinioAdapter) CopyFrom(src Adapter, srcFile, dstFile string) (err error) {
+	source, ok := src.(*MinioAdapter)
+	if !ok || source.config.EndPoint != adapter.config.EndPoint || source.config.AccessKey != adapter.config.AccessKey {
+		return CrossBucketUnsupported
 	}
+	defer adapter.log(OperationCopy, srcFile, time.Now(), &err)
+	return adapter.copyObject(source, srcFile, dstFile)
+}
 
-	_, err := adapter.client.CopyObject(context.Background(), dst, src)
+// copyObject 服务端复制, 源对象的SSE-C密钥取自源适配器
+// 源文件超过单次复制上限时使用 ComposeObject 分片复制
+func (adapter *MinioAdapter) copyObject(source *MinioAdapter, srcFile, dstFile string) error {
+	sse, err := adapter.sse()
 	if err != nil {
-		return false, err
+		return err
+	}
+	customerKey, err := source.customerKey()
+	if err != nil {
+		return err
+	}
+	src := minio.CopySrcOptions{
+		Bucket:     source.config.BucketName,
+		Object:     srcFile,
+		Encryption: customerKey,
 	}
 
-	return true, nil
+	dst := minio.CopyDestOptions{
+		Bucket:     adapter.config.BucketName,
+		Object:     dstFile,
+		Encryption: sse,
+	}
+
+	var info minio.ObjectInfo
+	return copyWithFallback(maxSingleCopySize, func() error {
+		_, err := adapter.client.CopyObject(context.Background(), dst, src)
+		return err
+	}, func() (int64, error) {
+		var err error
+		info, err = source.stat(srcFile)
+		return info.Size, err
+	}, func(int64) error {
+		// 分片复制只继承自定义元数据, 文件类型与内容编码需显式设置
+		dst.ReplaceMetadata = true
+		dst.UserMetadata = make(map[string]string, len(info.UserMetadata)+1)
+		for key, value := range info.UserMetadata {
+			dst.UserMetadata[key] = value
+		}
+		dst.UserMetadata["Content-Type"] = info.ContentType
+		if contentEncoding := info.Metadata.Get("Content-Encoding"); contentEncoding != "" {
+			dst.UserMetadata["Content-Encoding"] = contentEncoding
+		}
+		_, err := adapter.client.ComposeObject(context.Background(), dst, src)
+		return err
+	})
 }
 
-func (adapter *MinioAdapter) Move(dstFile, srcFile string) (bool, error) {
-	_, err := adapter.Copy(srcFile, dstFile)
+func (adapter *MinioAdapter) Move(dstFile, srcFile string) (_ bool, err error) {
+	defer adapter.log(OperationMove, srcFile, time.Now(), &err)
+	_, err = adapter.Copy(srcFile, dstFile)
 	if err != nil {
 		return false, err
 	}
@@ -142,33 +354,68 @@ func (adapter *MinioAdapter) Move(dstFile, srcFile string) (bool, error) {
 	return true, nil
 }
 
-func (adapter *MinioAdapter) Delete(file string) (bool, error) {
-	err := adapter.client.RemoveObject(context.Background(), adapter.config.BucketName, file, minio.RemoveObjectOptions{GovernanceBypass: true})
+func (adapter *MinioAdapter) Delete(file string) (_ bool, err error) {
+	defer adapter.log(OperationDelete, file, time.Now(), &err)
+	err = adapter.client.RemoveObject(context.Background(), adapter.config.BucketName, file, minio.RemoveObjectOptions{GovernanceBypass: true})
 	if err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-func (adapter *MinioAdapter) MultipleDelete(fileList []string) (bool, error) {
-	for _, file := range fileList {
-		err := adapter.client.RemoveObject(context.Background(), adapter.config.BucketName, file, minio.RemoveObjectOptions{GovernanceBypass: true})
-		if err != nil {
+func (adapter *MinioAdapter) MultipleDelete(fileList []string) (_ bool, err error) {
+	defer adapter.log(OperationMultipleDelete, "", time.Now(), &err)
+	for _, chunk := range batchChunks(fileList, maxBatchKeys) {
+		var results []BatchResult
+		if results, err = adapter.deleteObjects(chunk); err != nil {
+			return false, err
+		}
+		if err = batchError(results); err != nil {
 			return false, err
 		}
 	}
 	return true, nil
 }
 
-func (adapter *MinioAdapter) MkDir(dir string, mode os.FileMode) (bool, error) {
+// DeleteObjects 批量删除文件, 返回每个文件的删除结果
+func (adapter *MinioAdapter) DeleteObjects(fileList []string) (_ []BatchResult, err error) {
+	defer adapter.log(OperationMultipleDelete, "", time.Now(), &err)
+	return adapter.deleteObjects(fileList)
+}
+
+func (adapter *MinioAdapter) deleteObjects(fileList []string) ([]BatchResult, error) {
+	objects := make(chan minio.ObjectInfo, len(fileList))
+	for _, file := range fileList {
+		objects <- minio.ObjectInfo{Key: file}
+	}
+	close(objects)
+
+	failed := make(map[string]error)
+	for result := range adapter.client.RemoveObjectsWithResult(context.Background(), adapter.config.BucketName, objects, minio.RemoveObjectsOptions{GovernanceBypass: true}) {
+		if result.Err == nil {
+			continue
+		}
+		// 桶名无效等请求级错误不带文件名
+		if result.ObjectName == "" {
+			return nil, result.Err
+		}
+		failed[result.ObjectName] = result.Err
+	}
+	return batchResults(fileList, failed), nil
+}
+
+func (adapter *MinioAdapter) MkDir(dir string, mode os.FileMode) (_ bool, err error) {
+	defer adapter.log(OperationMkDir, dir, time.Now(), &err)
 	return true, nil
 }
 
-func (adapter *MinioAdapter) DeleteDir(dir string) (bool, error) {
+func (adapter *MinioAdapter) DeleteDir(dir string) (_ bool, err error) {
+	defer adapter.log(OperationDeleteDir, dir, time.Now(), &err)
 	return true, nil
 }
 
-func (adapter *MinioAdapter) List(dir string, iterable func(attribute storage.Attribute)) error {
+func (adapter *MinioAdapter) List(dir string, iterable func(attribute storage.Attribute)) (err error) {
+	defer adapter.log(OperationList, dir, time.Now(), &err)
 	dir = strings.TrimRight(dir, "/") + "/"
 	if dir == "/" {
 		dir = ""
@@ -179,23 +426,37 @@ func (adapter *MinioAdapter) List(dir string, iterable func(attribute storage.At
 		Prefix:    dir,
 	}
 
+	policy := adapter.policy()
 	for object := range adapter.client.ListObjects(context.Background(), adapter.config.BucketName, opts) {
 		if object.Err != nil {
-			fmt.Println(object.Err)
-			continue
+			return object.Err
 		}
 
 		names := strings.Split(strings.TrimRight(object.Key, "/"), "/")
+		visibility := adapter.visibility(policy, object.Key)
 		if string(object.Key[len(object.Key)-1]) == "/" {
-			iterable(storage.NewDirectoryAttribute(names[len(names)-1], object.Key, "", 0))
+			iterable(storage.NewDirectoryAttribute(names[len(names)-1], object.Key, visibility, 0))
 		} else {
-			iterable(storage.NewFileAttribute(names[len(names)-1], object.Key, "", object.ContentType, object.Size, object.LastModified.Unix()))
+			mimeType := storedMimeType(adapter.config.MimeDetector, object.Key, object.ContentType)
+			iterable(storage.NewFileAttribute(names[len(names)-1], object.Key, visibility, mimeType, object.Size, object.LastModified.Unix()).
+				WithETag(object.ETag).
+				WithStorageClass(object.StorageClass))
 		}
 	}
 
 	return nil
 }
 
+// HealthCheck 检查存储桶是否可访问
+func (adapter *MinioAdapter) HealthCheck(ctx context.Context) HealthStatus {
+	start := time.Now()
+	exists, err := adapter.client.BucketExists(ctx, adapter.config.BucketName)
+	if err == nil && !exists {
+		err = BucketNotExists
+	}
+	return newHealthStatus(start, err)
+}
+
 func (adapter *MinioAdapter) FullPath(path string) string {
 	if adapter.config.IsPrivate {
 		signUrl, err := adapter.client.PresignedGetObject(
@@ -251,3 +512,284 @@ func (adapter *MinioAdapter) OriginalPath(fullPath string) string {
 
 	return originalPath
 }
+
+// SignURL 生成预签名URL
+func (adapter *MinioAdapter) SignURL(path, method string, expires time.Duration) (string, error) {
+	u, err := adapter.client.Presign(context.Background(), method, adapter.config.BucketName, path, expires, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// SetVisibility 通过存储桶策略设置文件或前缀(以 / 结尾)的匿名读取权限
+// 每个路径对应一条策略语句, 存储桶策略大小有上限(AWS S3为20KB), 大量文件应按前缀设置;
+// 读改写仅在本适配器内串行, 多个进程同时修改策略时后写入者覆盖先写入者
+func (adapter *MinioAdapter) SetVisibility(path string, visibility storage.Visibility) error {
+	if err := validVisibility(visibility); err != nil {
+		return err
+	}
+	adapter.policyMu.Lock()
+	defer adapter.policyMu.Unlock()
+
+	ctx := context.Background()
+	current, err := adapter.client.GetBucketPolicy(ctx, adapter.config.BucketName)
+	if err != nil {
+		return err
+	}
+	policy, err := parseBucketPolicy(current)
+	if err != nil {
+		return errors.Wrap(err, "minio bucket policy parse error")
+	}
+	policy.set(objectResource(adapter.config.BucketName, path), visibility)
+	return adapter.client.SetBucketPolicy(ctx, adapter.config.BucketName, policy.String())
+}
+
+// Visibility 根据存储桶策略获取文件或前缀的可见性
+func (adapter *MinioAdapter) Visibility(path string) (storage.Visibility, error) {
+	current, err := adapter.client.GetBucketPolicy(context.Background(), adapter.config.BucketName)
+	if err != nil {
+		return "", err
+	}
+	policy, err := parseBucketPolicy(current)
+	if err != nil {
+		return "", errors.Wrap(err, "minio bucket policy parse error")
+	}
+	return policy.visibility(objectResource(adapter.config.BucketName, path)), nil
+}
+
+// policy 开启ResolveVisibility时读取存储桶策略, 未开启、无权限读取或解析失败时返回nil
+func (adapter *MinioAdapter) policy() *bucketPolicy {
+	if !adapter.config.ResolveVisibility {
+		return nil
+	}
+	current, err := adapter.client.GetBucketPolicy(context.Background(), adapter.config.BucketName)
+	if err != nil {
+		return nil
+	}
+	policy, _ := parseBucketPolicy(current)
+	return policy
+}
+
+// visibility 根据存储桶策略计算可见性, 没有策略信息时按配置的存储桶可见性
+func (adapter *MinioAdapter) visibility(policy *bucketPolicy, path string) storage.Visibility {
+	if policy == nil {
+		return defaultVisibility(adapter.config.IsPrivate)
+	}
+	return policy.visibility(objectResource(adapter.config.BucketName, path))
+}
+
+// GetMetadata 获取对象自定义元数据(x-amz-meta-*)
+func (adapter *MinioAdapter) GetMetadata(path string) (map[string]string, error) {
+	info, err := adapter.stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return userMetadata(info.Metadata, "X-Amz-"), nil
+}
+
+// SetMetadata 以 REPLACE 方式原地复制对象写入自定义元数据, 保留内容类型等标准请求头及存储类型
+func (adapter *MinioAdapter) SetMetadata(path string, metadata map[string]string, merge bool) error {
+	info, err := adapter.stat(path)
+	if err != nil {
+		return err
+	}
+	metadata, err = resolveMetadata(userMetadata(info.Metadata, "X-Amz-"), metadata, merge)
+	if err != nil {
+		return err
+	}
+
+	headers := make(map[string]string, len(metadata)+len(contentHeaderNames)+1)
+	for _, name := range contentHeaderNames {
+		if value := info.Metadata.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	if info.StorageClass != "" && info.StorageClass != "STANDARD" {
+		headers["X-Amz-Storage-Class"] = info.StorageClass
+	}
+	for key, value := range metadata {
+		headers["X-Amz-Meta-"+key] = value
+	}
+
+	sse, err := adapter.sse()
+	if err != nil {
+		return err
+	}
+	customerKey, err := adapter.customerKey()
+	if err != nil {
+		return err
+	}
+	_, err = adapter.client.CopyObject(context.Background(), minio.CopyDestOptions{
+		Bucket:          adapter.config.BucketName,
+		Object:          path,
+		Encryption:      sse,
+		UserMetadata:    headers,
+		ReplaceMetadata: true,
+	}, minio.CopySrcOptions{
+		Bucket:     adapter.config.BucketName,
+		Object:     path,
+		Encryption: customerKey,
+	})
+	return err
+}
+
+// PutTags 设置对象标签
+func (adapter *MinioAdapter) PutTags(path string, objectTags map[string]string) error {
+	if err := validTags(objectTags); err != nil {
+		return err
+	}
+	t, err := tags.NewTags(objectTags, true)
+	if err != nil {
+		return err
+	}
+	err = adapter.client.PutObjectTagging(context.Background(), adapter.config.BucketName, path, t, minio.PutObjectTaggingOptions{})
+	return notExists(err)
+}
+
+// GetTags 获取对象标签
+func (adapter *MinioAdapter) GetTags(path string) (map[string]string, error) {
+	t, err := adapter.client.GetObjectTagging(context.Background(), adapter.config.BucketName, path, minio.GetObjectTaggingOptions{})
+	if err != nil {
+		return nil, notExists(err)
+	}
+	return t.ToMap(), nil
+}
+
+// DeleteTags 删除对象标签
+func (adapter *MinioAdapter) DeleteTags(path string) error {
+	err := adapter.client.RemoveObjectTagging(context.Background(), adapter.config.BucketName, path, minio.RemoveObjectTaggingOptions{})
+	return notExists(err)
+}
+
+// ListVersions 列出对象的全部版本及删除标记
+func (adapter *MinioAdapter) ListVersions(path string) ([]ObjectVersion, error) {
+	var versions []ObjectVersion
+	for object := range adapter.client.ListObjects(context.Background(), adapter.config.BucketName, minio.ListObjectsOptions{
+		Prefix:       path,
+		WithVersions: true,
+	}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		if object.Key != path {
+			continue
+		}
+		versions = append(versions, ObjectVersion{
+			VersionID:      object.VersionID,
+			IsLatest:       object.IsLatest,
+			IsDeleteMarker: object.IsDeleteMarker,
+			Size:           object.Size,
+			ETag:           object.ETag,
+			LastModified:   object.LastModified,
+		})
+	}
+	if len(versions) == 0 {
+		return nil, FileNotExists
+	}
+	return sortVersions(versions), nil
+}
+
+// ReadVersion 读取对象的指定版本
+func (adapter *MinioAdapter) ReadVersion(path, versionID string) (io.ReadCloser, error) {
+	customerKey, err := adapter.customerKey()
+	if err != nil {
+		return nil, err
+	}
+	object, err := adapter.client.GetObject(context.Background(), adapter.config.BucketName, path, minio.GetObjectOptions{
+		ServerSideEncryption: customerKey,
+		VersionID:            versionID,
+	})
+	if err != nil {
+		return nil, notExists(err)
+	}
+	if _, err = object.Stat(); err != nil {
+		_ = object.Close()
+		return nil, notExists(err)
+	}
+	return object, nil
+}
+
+// RestoreVersion 将指定版本复制为当前版本
+func (adapter *MinioAdapter) RestoreVersion(path, versionID string) error {
+	sse, err := adapter.sse()
+	if err != nil {
+		return err
+	}
+	customerKey, err := adapter.customerKey()
+	if err != nil {
+		return err
+	}
+	_, err = adapter.client.CopyObject(context.Background(), minio.CopyDestOptions{
+		Bucket:     adapter.config.BucketName,
+		Object:     path,
+		Encryption: sse,
+	}, minio.CopySrcOptions{
+		Bucket:     adapter.config.BucketName,
+		Object:     path,
+		VersionID:  versionID,
+		Encryption: customerKey,
+	})
+	return notExists(err)
+}
+
+// DeleteVersion 永久删除对象的指定版本
+func (adapter *MinioAdapter) DeleteVersion(path, versionID string) error {
+	err := adapter.client.RemoveObject(context.Background(), adapter.config.BucketName, path, minio.RemoveObjectOptions{
+		VersionID:        versionID,
+		GovernanceBypass: true,
+	})
+	return notExists(err)
+}
+
+// stat 读取对象元信息
+func (adapter *MinioAdapter) stat(path string) (minio.ObjectInfo, error) {
+	customerKey, err := adapter.customerKey()
+	if err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	info, err := adapter.client.StatObject(context.Background(), adapter.config.BucketName, path, minio.StatObjectOptions{
+		ServerSideEncryption: customerKey,
+	})
+	if err != nil {
+		return minio.ObjectInfo{}, notExists(err)
+	}
+	return info, nil
+}
+
+// WithServerSideEncryption 返回使用指定服务端加密方式的适配器副本
+func (adapter *MinioAdapter) WithServerSideEncryption(sse ServerSideEncryption) Adapter {
+	clone := *adapter
+	clone.config.Encryption = sse
+	return &clone
+}
+
+// sse 上传及复制目标使用的服务端加密
+func (adapter *MinioAdapter) sse() (encrypt.ServerSide, error) {
+	sse := adapter.config.Encryption
+	if err := sse.validate("minio"); err != nil {
+		return nil, err
+	}
+	switch sse.Type {
+	case SSES3:
+		return encrypt.NewSSE(), nil
+	case SSEKMS:
+		return encrypt.NewSSEKMS(sse.KMSKeyID, nil)
+	case SSEC:
+		return encrypt.NewSSEC(sse.CustomerKey)
+	}
+	return nil, nil
+}
+
+// customerKey 读取SSE-C加密对象时需携带的客户密钥, 其他加密方式返回nil
+func (adapter *MinioAdapter) customerKey() (encrypt.ServerSide, error) {
+	if adapter.config.Encryption.Type != SSEC {
+		return nil, nil
+	}
+	return adapter.sse()
+}
+
+// log 记录操作日志
+func (adapter *MinioAdapter) log(operation, path string, start time.Time, err *error) {
+	logOperation(adapter.config.Logger, "minio", operation, path, start, err)
+}
@@ -0,0 +1,394 @@
+package adapter
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+var (
+	MountNotFound      = errors.New("mount point does not exists")
+	MountAlreadyExists = errors.New("mount point already exists")
+	CrossMountCover    = errors.New("cover source and target must be on the same mount point")
+)
+
+const schemeSeparator = "://"
+
+// MountManager 挂载管理器
+// 按 scheme://path 或路径前缀将操作路由到已挂载的存储适配器
+type MountManager struct {
+	mu       sync.RWMutex
+	schemes  map[string]Adapter
+	prefixes []prefixMount
+}
+
+type prefixMount struct {
+	prefix  string
+	adapter Adapter
+}
+
+// mountPath 路由结果
+type mountPath struct {
+	adapter Adapter
+	mount   string // 挂载点, scheme:// 或 前缀/
+	path    string // 适配器内路径
+}
+
+// outer 将适配器内路径还原为挂载管理器路径
+func (mp mountPath) outer(path string) string {
+	return mp.mount + strings.TrimLeft(path, "/")
+}
+
+func NewMountManager() *MountManager {
+	return &MountManager{
+		schemes: make(map[string]Adapter),
+	}
+}
+
+// Mount 按scheme挂载适配器
+// @param scheme string 如 oss, 通过 oss://path 访问
+// @param adapter Adapter 存储适配器
+func (manager *MountManager) Mount(scheme string, adapter Adapter) error {
+	scheme = strings.TrimSuffix(scheme, schemeSeparator)
+	if scheme == "" || strings.ContainsAny(scheme, "/:") {
+		return errors.Errorf("invalid mount scheme %q", scheme)
+	}
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if _, ok := manager.schemes[scheme]; ok {
+		return MountAlreadyExists
+	}
+	manager.schemes[scheme] = adapter
+
+	return nil
+}
+
+// MountPrefix 按路径前缀挂载适配器, 空前缀作为默认挂载点
+// @param prefix string 路径前缀, 如 exports, 通过 exports/path 访问
+// @param adapter Adapter 存储适配器
+func (manager *MountManager) MountPrefix(prefix string, adapter Adapter) error {
+	prefix = strings.Trim(prefix, "/")
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for _, mount := range manager.prefixes {
+		if mount.prefix == prefix {
+			return MountAlreadyExists
+		}
+	}
+
+	manager.prefixes = append(manager.prefixes, prefixMount{prefix: prefix, adapter: adapter})
+	// 最长前缀优先匹配
+	sort.SliceStable(manager.prefixes, func(i, j int) bool {
+		return len(manager.prefixes[i].prefix) > len(manager.prefixes[j].prefix)
+	})
+
+	return nil
+}
+
+// Unmount 卸载挂载点
+// @param mount string scheme 或 路径前缀
+func (manager *MountManager) Unmount(mount string) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	delete(manager.schemes, strings.TrimSuffix(mount, schemeSeparator))
+
+	prefix := strings.Trim(mount, "/")
+	for i, m := range manager.prefixes {
+		if m.prefix == prefix {
+			manager.prefixes = append(manager.prefixes[:i], manager.prefixes[i+1:]...)
+			break
+		}
+	}
+}
+
+// Mounts 已挂载的适配器列表, key为挂载点
+func (manager *MountManager) Mounts() map[string]Adapter {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	mounts := make(map[string]Adapter, len(manager.schemes)+len(manager.prefixes))
+	for scheme, adapter := range manager.schemes {
+		mounts[scheme+schemeSeparator] = adapter
+	}
+	for _, m := range manager.prefixes {
+		mounts[m.prefix] = m.adapter
+	}
+
+	return mounts
+}
+
+// resolve 路由路径到挂载的适配器
+func (manager *MountManager) resolve(path string) (mountPath, error) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	if i := strings.Index(path, schemeSeparator); i > 0 {
+		scheme := path[:i]
+		adapter, ok := manager.schemes[scheme]
+		if !ok {
+			return mountPath{}, errors.Wrap(MountNotFound, scheme)
+		}
+		return mountPath{
+			adapter: adapter,
+			mount:   scheme + schemeSeparator,
+			path:    path[i+len(schemeSeparator):],
+		}, nil
+	}
+
+	trimmed := strings.TrimLeft(path, "/")
+	for _, m := range manager.prefixes {
+		if m.prefix == "" {
+			return mountPath{adapter: m.adapter, path: path}, nil
+		}
+		if trimmed == m.prefix || strings.HasPrefix(trimmed, m.prefix+"/") {
+			return mountPath{
+				adapter: m.adapter,
+				mount:   m.prefix + "/",
+				path:    strings.TrimPrefix(trimmed[len(m.prefix):], "/"),
+			}, nil
+		}
+	}
+
+	return mountPath{}, errors.Wrap(MountNotFound, path)
+}
+
+// rebase 将属性路径还原为挂载管理器路径
+func (mp mountPath) rebase(attribute storage.Attribute) storage.Attribute {
+	if mp.mount == "" {
+		return attribute
+	}
+	switch attr := attribute.(type) {
+	case *storage.FileAttribute:
+		return attr.WithPath(mp.outer(attr.Path()))
+	case *storage.DirectoryAttribute:
+		return attr.WithPath(mp.outer(attr.Path()))
+	}
+	return attribute
+}
+
+func (manager *MountManager) Info(file string) (storage.Attribute, error) {
+	mp, err := manager.resolve(file)
+	if err != nil {
+		return nil, err
+	}
+	attribute, err := mp.adapter.Info(mp.path)
+	if err != nil {
+		return nil, err
+	}
+	return mp.rebase(attribute), nil
+}
+
+func (manager *MountManager) HasFile(file string) bool {
+	mp, err := manager.resolve(file)
+	if err != nil {
+		return false
+	}
+	return mp.adapter.HasFile(mp.path)
+}
+
+func (manager *MountManager) HasDir(file string) bool {
+	mp, err := manager.resolve(file)
+	if err != nil {
+		return false
+	}
+	return mp.adapter.HasDir(mp.path)
+}
+
+func (manager *MountManager) Read(file string) (io.ReadCloser, error) {
+	mp, err := manager.resolve(file)
+	if err != nil {
+		return nil, err
+	}
+	return mp.adapter.Read(mp.path)
+}
+
+func (manager *MountManager) Save(dstFile string, srcFile io.Reader, mimeType string) (bool, error) {
+	mp, err := manager.resolve(dstFile)
+	if err != nil {
+		return false, err
+	}
+	return mp.adapter.Save(mp.path, srcFile, mimeType)
+}
+
+func (manager *MountManager) Cover(sourceImagePath, coverImagePath string, width, height uint) error {
+	src, err := manager.resolve(sourceImagePath)
+	if err != nil {
+		return err
+	}
+	dst, err := manager.resolve(coverImagePath)
+	if err != nil {
+		return err
+	}
+	if src.adapter != dst.adapter {
+		return CrossMountCover
+	}
+	return src.adapter.Cover(src.path, dst.path, width, height)
+}
+
+func (manager *MountManager) Copy(srcFile, dstFile string) (bool, error) {
+	src, err := manager.resolve(srcFile)
+	if err != nil {
+		return false, err
+	}
+	dst, err := manager.resolve(dstFile)
+	if err != nil {
+		return false, err
+	}
+
+	return CopyAcross(src.adapter, src.path, dst.adapter, dst.path)
+}
+
+func (manager *MountManager) Move(dstFile, srcFile string) (bool, error) {
+	src, err := manager.resolve(srcFile)
+	if err != nil {
+		return false, err
+	}
+	dst, err := manager.resolve(dstFile)
+	if err != nil {
+		return false, err
+	}
+
+	if src.adapter == dst.adapter {
+		return src.adapter.Move(dst.path, src.path)
+	}
+
+	if _, err = CopyAcross(src.adapter, src.path, dst.adapter, dst.path); err != nil {
+		return false, err
+	}
+
+	return src.adapter.Delete(src.path)
+}
+
+func (manager *MountManager) Delete(file string) (bool, error) {
+	mp, err := manager.resolve(file)
+	if err != nil {
+		return false, err
+	}
+	return mp.adapter.Delete(mp.path)
+}
+
+func (manager *MountManager) MultipleDelete(fileList []string) (bool, error) {
+	// 按适配器分组, 每组只调用一次批量删除
+	var order []Adapter
+	groups := make(map[Adapter][]string)
+	for _, file := range fileList {
+		mp, err := manager.resolve(file)
+		if err != nil {
+			return false, err
+		}
+		if _, ok := groups[mp.adapter]; !ok {
+			order = append(order, mp.adapter)
+		}
+		groups[mp.adapter] = append(groups[mp.adapter], mp.path)
+	}
+
+	for _, adapter := range order {
+		if _, err := adapter.MultipleDelete(groups[adapter]); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (manager *MountManager) MkDir(dir string, mode os.FileMode) (bool, error) {
+	mp, err := manager.resolve(dir)
+	if err != nil {
+		return false, err
+	}
+	return mp.adapter.MkDir(mp.path, mode)
+}
+
+func (manager *MountManager) DeleteDir(dir string) (bool, error) {
+	mp, err := manager.resolve(dir)
+	if err != nil {
+		return false, err
+	}
+	return mp.adapter.DeleteDir(mp.path)
+}
+
+func (manager *MountManager) List(dir string, iterable func(attribute storage.Attribute)) error {
+	mp, err := manager.resolve(dir)
+	if err != nil {
+		return err
+	}
+	return mp.adapter.List(mp.path, func(attribute storage.Attribute) {
+		iterable(mp.rebase(attribute))
+	})
+}
+
+func (manager *MountManager) FullPath(path string) string {
+	mp, err := manager.resolve(path)
+	if err != nil {
+		return ""
+	}
+	return mp.adapter.FullPath(mp.path)
+}
+
+func (manager *MountManager) OriginalPath(fullPath string) string {
+	if strings.Contains(fullPath, schemeSeparator) {
+		if mp, err := manager.resolve(fullPath); err == nil {
+			return mp.outer(mp.path)
+		}
+	}
+
+	mounts := manager.Mounts()
+	names := make([]string, 0, len(mounts))
+	for mount := range mounts {
+		names = append(names, mount)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return len(names[i]) > len(names[j])
+	})
+
+	for _, mount := range names {
+		adapter := mounts[mount]
+		base := adapter.FullPath("")
+		if i := strings.Index(base, "?"); i >= 0 {
+			base = base[:i]
+		}
+		if base == "" || !strings.HasPrefix(fullPath, base) {
+			continue
+		}
+		path := adapter.OriginalPath(fullPath)
+		if strings.HasSuffix(mount, schemeSeparator) {
+			return mount + path
+		}
+		if mount == "" {
+			return path
+		}
+		return mount + "/" + path
+	}
+
+	return fullPath
+}
+
+// streamCopy 跨适配器复制, 读取原文件并写入目标适配器
+func streamCopy(src Adapter, srcFile string, dst Adapter, dstFile string) (bool, error) {
+	var mimeType string
+	if attribute, err := src.Info(srcFile); err == nil {
+		if fileAttribute, ok := attribute.(*storage.FileAttribute); ok {
+			mimeType = fileAttribute.MimeType()
+		}
+	}
+
+	reader, err := src.Read(srcFile)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	return dst.Save(dstFile, reader, mimeType)
+}
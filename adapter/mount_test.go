@@ -0,0 +1,114 @@
+package adapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+func TestMountManagerRouting(t *testing.T) {
+	mounts := map[string]Adapter{
+		"tmp":         NewLocalAdapter(LocalConfig{BasePath: t.TempDir()}),
+		"exports":     NewLocalAdapter(LocalConfig{BasePath: t.TempDir()}),
+		"exports/big": NewLocalAdapter(LocalConfig{BasePath: t.TempDir()}),
+		"":            NewLocalAdapter(LocalConfig{BasePath: t.TempDir()}),
+	}
+	manager := NewMountManager()
+	if err := manager.Mount("tmp", mounts["tmp"]); err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{"exports", "exports/big", ""} {
+		if err := manager.MountPrefix(prefix, mounts[prefix]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := manager.MountPrefix("/exports/", mounts["tmp"]); !errors.Is(err, MountAlreadyExists) {
+		t.Fatalf("duplicate prefix: got %v, want MountAlreadyExists", err)
+	}
+
+	// 最长前缀优先, 未匹配的路径落到默认挂载点
+	tests := []struct {
+		path  string
+		mount string
+		inner string
+	}{
+		{path: "tmp://a.txt", mount: "tmp", inner: "a.txt"},
+		{path: "exports/b.txt", mount: "exports", inner: "b.txt"},
+		{path: "exports/big/c.txt", mount: "exports/big", inner: "c.txt"},
+		{path: "exports-old.txt", mount: "", inner: "exports-old.txt"},
+		{path: "d.txt", mount: "", inner: "d.txt"},
+	}
+	for _, test := range tests {
+		if _, err := manager.Save(test.path, bytes.NewReader([]byte(test.path)), "text/plain"); err != nil {
+			t.Fatalf("save %s: %v", test.path, err)
+		}
+		for prefix, adapter := range mounts {
+			if has := adapter.HasFile(test.inner); has != (prefix == test.mount) {
+				t.Errorf("%s: mount %q has %s = %v", test.path, prefix, test.inner, has)
+			}
+		}
+		attribute, err := manager.Info(test.path)
+		if err != nil {
+			t.Fatalf("info %s: %v", test.path, err)
+		}
+		if attribute.Path() != test.path {
+			t.Errorf("info %s: got path %s", test.path, attribute.Path())
+		}
+	}
+
+	var listed []string
+	if err := manager.List("exports/big", func(attribute storage.Attribute) {
+		if !attribute.IsDir() {
+			listed = append(listed, attribute.Path())
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0] != "exports/big/c.txt" {
+		t.Errorf("listed %v, want exports/big/c.txt", listed)
+	}
+
+	if _, err := manager.Read("s3://a.txt"); !errors.Is(err, MountNotFound) {
+		t.Errorf("unknown scheme: got %v, want MountNotFound", err)
+	}
+	manager.Unmount("")
+	if _, err := manager.Read("d.txt"); !errors.Is(err, MountNotFound) {
+		t.Errorf("after unmounting the default: got %v, want MountNotFound", err)
+	}
+}
+
+func TestMountManagerMove(t *testing.T) {
+	tmp := NewLocalAdapter(LocalConfig{BasePath: t.TempDir()})
+	exports := NewLocalAdapter(LocalConfig{BasePath: t.TempDir()})
+	manager := NewMountManager()
+	if err := manager.Mount("tmp", tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Mount("exports", exports); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Save("tmp://a.txt", bytes.NewReader([]byte("content")), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一挂载点内移动, 参数顺序为目标在前
+	if _, err := manager.Move("tmp://b.txt", "tmp://a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if tmp.HasFile("a.txt") || !tmp.HasFile("b.txt") {
+		t.Fatal("move within a mount went the wrong way")
+	}
+
+	// 跨挂载点移动, 复制后删除源文件
+	if _, err := manager.Move("exports://c.txt", "tmp://b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if tmp.HasFile("b.txt") {
+		t.Error("source still exists after moving across mounts")
+	}
+	if got, err := readAll(exports, "c.txt"); err != nil || string(got) != "content" {
+		t.Errorf("moved across mounts: got %q, %v", got, err)
+	}
+}
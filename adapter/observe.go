@@ -0,0 +1,292 @@
+package adapter
+
+import (
+	"io"
+	"os"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+// 操作名称
+const (
+	OperationInfo           = "info"
+	OperationHasFile        = "has_file"
+	OperationHasDir         = "has_dir"
+	OperationRead           = "read"
+	OperationSave           = "save"
+	OperationCover          = "cover"
+	OperationCopy           = "copy"
+	OperationMove           = "move"
+	OperationDelete         = "delete"
+	OperationMultipleDelete = "multiple_delete"
+	OperationMkDir          = "mkdir"
+	OperationDeleteDir      = "delete_dir"
+	OperationList           = "list"
+)
+
+// Observer 存储操作观察者
+type Observer interface {
+	// StartSpan 操作开始, 返回的Span在操作结束时调用End
+	// @param backend string 存储名称
+	// @param operation string 操作名称
+	// @param path string 文件路径
+	StartSpan(backend, operation, path string) Span
+}
+
+// Span 单次存储操作
+type Span interface {
+	// End 操作结束
+	// @param bytes int64 读写字节数, 非读写操作为0
+	// @param err error 操作错误
+	End(bytes int64, err error)
+}
+
+// MultiObserver 组合多个观察者
+func MultiObserver(observers ...Observer) Observer {
+	return multiObserver(observers)
+}
+
+type multiObserver []Observer
+
+func (observers multiObserver) StartSpan(backend, operation, path string) Span {
+	spans := make(multiSpan, len(observers))
+	for i, observer := range observers {
+		spans[i] = observer.StartSpan(backend, operation, path)
+	}
+	return spans
+}
+
+type multiSpan []Span
+
+func (spans multiSpan) End(bytes int64, err error) {
+	for _, span := range spans {
+		span.End(bytes, err)
+	}
+}
+
+// InstrumentedAdapter 可观测装饰器, 记录每次操作的耗时、读写字节数及错误
+type InstrumentedAdapter struct {
+	adapter  Adapter
+	backend  string
+	observer Observer
+}
+
+// NewInstrumentedAdapter 创建可观测装饰器
+// @param adapter Adapter 存储适配器
+// @param backend string 存储名称, 作为指标与链路的backend标签
+// @param observer Observer 观察者
+func NewInstrumentedAdapter(adapter Adapter, backend string, observer Observer) Adapter {
+	return &InstrumentedAdapter{
+		adapter:  adapter,
+		backend:  backend,
+		observer: observer,
+	}
+}
+
+// Unwrap 返回被装饰的适配器
+func (adapter *InstrumentedAdapter) Unwrap() Adapter {
+	return adapter.adapter
+}
+
+func (adapter *InstrumentedAdapter) start(operation, path string) Span {
+	return adapter.observer.StartSpan(adapter.backend, operation, path)
+}
+
+func (adapter *InstrumentedAdapter) Info(file string) (storage.Attribute, error) {
+	span := adapter.start(OperationInfo, file)
+	attribute, err := adapter.adapter.Info(file)
+	span.End(0, err)
+	return attribute, err
+}
+
+func (adapter *InstrumentedAdapter) HasFile(file string) bool {
+	span := adapter.start(OperationHasFile, file)
+	result := adapter.adapter.HasFile(file)
+	span.End(0, nil)
+	return result
+}
+
+func (adapter *InstrumentedAdapter) HasDir(file string) bool {
+	span := adapter.start(OperationHasDir, file)
+	result := adapter.adapter.HasDir(file)
+	span.End(0, nil)
+	return result
+}
+
+// Read 读取字节数在关闭读取器时上报
+func (adapter *InstrumentedAdapter) Read(file string) (io.ReadCloser, error) {
+	span := adapter.start(OperationRead, file)
+	reader, err := adapter.adapter.Read(file)
+	if err != nil {
+		span.End(0, err)
+		return nil, err
+	}
+	return &observedReader{ReadCloser: reader, span: span}, nil
+}
+
+func (adapter *InstrumentedAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (bool, error) {
+	span := adapter.start(OperationSave, dstFile)
+	counter := &countingReader{Reader: srcFile}
+	result, err := adapter.adapter.Save(dstFile, counter, mimeType)
+	span.End(counter.n, err)
+	return result, err
+}
+
+// SaveEncoded 转发至底层适配器, 记录为save操作
+func (adapter *InstrumentedAdapter) SaveEncoded(dstFile string, srcFile io.Reader, mimeType string, encoding ContentEncoding, size int64) error {
+	encoder := contentEncoder(adapter.adapter)
+	if encoder == nil {
+		return errors.Wrap(CompressionUnsupported, "content encoding")
+	}
+	span := adapter.start(OperationSave, dstFile)
+	counter := &countingReader{Reader: srcFile}
+	err := encoder.SaveEncoded(dstFile, counter, mimeType, encoding, size)
+	span.End(counter.n, err)
+	return err
+}
+
+// SaveWithChecksum 转发至底层适配器的上传校验, 记录为save操作
+func (adapter *InstrumentedAdapter) SaveWithChecksum(dstFile string, srcFile io.Reader, mimeType string) (Checksums, error) {
+	saver := checksumSaver(adapter.adapter)
+	if saver == nil {
+		return Checksums{}, ChecksumUnsupported
+	}
+	span := adapter.start(OperationSave, dstFile)
+	counter := &countingReader{Reader: srcFile}
+	sums, err := saver.SaveWithChecksum(dstFile, counter, mimeType)
+	span.End(counter.n, err)
+	return sums, err
+}
+
+func (adapter *InstrumentedAdapter) Cover(sourceImagePath, coverImagePath string, width, height uint) error {
+	span := adapter.start(OperationCover, sourceImagePath)
+	err := adapter.adapter.Cover(sourceImagePath, coverImagePath, width, height)
+	span.End(0, err)
+	return err
+}
+
+func (adapter *InstrumentedAdapter) Copy(srcFile, dstFile string) (bool, error) {
+	span := adapter.start(OperationCopy, srcFile)
+	result, err := adapter.adapter.Copy(srcFile, dstFile)
+	span.End(0, err)
+	return result, err
+}
+
+func (adapter *InstrumentedAdapter) Move(dstFile, srcFile string) (bool, error) {
+	span := adapter.start(OperationMove, srcFile)
+	result, err := adapter.adapter.Move(dstFile, srcFile)
+	span.End(0, err)
+	return result, err
+}
+
+func (adapter *InstrumentedAdapter) Delete(file string) (bool, error) {
+	span := adapter.start(OperationDelete, file)
+	result, err := adapter.adapter.Delete(file)
+	span.End(0, err)
+	return result, err
+}
+
+func (adapter *InstrumentedAdapter) MultipleDelete(fileList []string) (bool, error) {
+	span := adapter.start(OperationMultipleDelete, "")
+	result, err := adapter.adapter.MultipleDelete(fileList)
+	span.End(0, err)
+	return result, err
+}
+
+// CopyFrom 转发至底层适配器的跨存储桶服务端复制, 记录为copy操作
+// 不满足服务端复制条件时没有发出请求, 由调用方回退为流式复制, 不记为失败
+func (adapter *InstrumentedAdapter) CopyFrom(src Adapter, srcFile, dstFile string) error {
+	copier := bucketCopier(adapter.adapter)
+	if copier == nil {
+		return CrossBucketUnsupported
+	}
+	span := adapter.start(OperationCopy, srcFile)
+	err := copier.CopyFrom(src, srcFile, dstFile)
+	if errors.Is(err, CrossBucketUnsupported) {
+		span.End(0, nil)
+	} else {
+		span.End(0, err)
+	}
+	return err
+}
+
+// DeleteObjects 转发至底层适配器的原生批量删除, 记录为multiple_delete操作
+func (adapter *InstrumentedAdapter) DeleteObjects(fileList []string) ([]BatchResult, error) {
+	deleter := batchDeleter(adapter.adapter)
+	if deleter == nil {
+		return nil, BatchDeleteUnsupported
+	}
+	span := adapter.start(OperationMultipleDelete, "")
+	results, err := deleter.DeleteObjects(fileList)
+	span.End(0, err)
+	return results, err
+}
+
+func (adapter *InstrumentedAdapter) MkDir(dir string, mode os.FileMode) (bool, error) {
+	span := adapter.start(OperationMkDir, dir)
+	result, err := adapter.adapter.MkDir(dir, mode)
+	span.End(0, err)
+	return result, err
+}
+
+func (adapter *InstrumentedAdapter) DeleteDir(dir string) (bool, error) {
+	span := adapter.start(OperationDeleteDir, dir)
+	result, err := adapter.adapter.DeleteDir(dir)
+	span.End(0, err)
+	return result, err
+}
+
+func (adapter *InstrumentedAdapter) List(dir string, iterable func(attribute storage.Attribute)) error {
+	span := adapter.start(OperationList, dir)
+	err := adapter.adapter.List(dir, iterable)
+	span.End(0, err)
+	return err
+}
+
+func (adapter *InstrumentedAdapter) FullPath(path string) string {
+	return adapter.adapter.FullPath(path)
+}
+
+func (adapter *InstrumentedAdapter) OriginalPath(fullPath string) string {
+	return adapter.adapter.OriginalPath(fullPath)
+}
+
+// countingReader 统计读取字节数
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (reader *countingReader) Read(p []byte) (int, error) {
+	n, err := reader.Reader.Read(p)
+	reader.n += int64(n)
+	return n, err
+}
+
+// observedReader 统计读取字节数, 关闭时结束Span
+type observedReader struct {
+	io.ReadCloser
+	span  Span
+	n     int64
+	err   error
+	ended bool
+}
+
+func (reader *observedReader) Read(p []byte) (int, error) {
+	n, err := reader.ReadCloser.Read(p)
+	reader.n += int64(n)
+	if err != nil && err != io.EOF {
+		reader.err = err
+	}
+	return n, err
+}
+
+func (reader *observedReader) Close() error {
+	err := reader.ReadCloser.Close()
+	if !reader.ended {
+		reader.ended = true
+		reader.span.End(reader.n, reader.err)
+	}
+	return err
+}
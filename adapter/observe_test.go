@@ -0,0 +1,59 @@
+package adapter
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+// recordedSpan 测试观察者记录的操作
+type recordedSpan struct {
+	operation string
+	path      string
+	bytes     int64
+	err       error
+}
+
+// recordingObserver 记录全部结束的操作
+type recordingObserver struct {
+	mu    sync.Mutex
+	spans []recordedSpan
+}
+
+func (observer *recordingObserver) StartSpan(_, operation, path string) Span {
+	return &recordingSpan{observer: observer, span: recordedSpan{operation: operation, path: path}}
+}
+
+type recordingSpan struct {
+	observer *recordingObserver
+	span     recordedSpan
+}
+
+func (span *recordingSpan) End(bytes int64, err error) {
+	span.span.bytes, span.span.err = bytes, err
+	span.observer.mu.Lock()
+	defer span.observer.mu.Unlock()
+	span.observer.spans = append(span.observer.spans, span.span)
+}
+
+func TestSaveWithChecksumThroughDecorators(t *testing.T) {
+	observer := &recordingObserver{}
+	local := NewLocalAdapter(LocalConfig{BasePath: t.TempDir()})
+	fs := NewInstrumentedAdapter(NewRetryAdapter(local, RetryConfig{}), "local", observer)
+
+	sums, err := SaveWithChecksum(fs, "a.txt", bytes.NewReader([]byte("content")), "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sums.SHA256 == "" {
+		t.Fatal("no checksum returned")
+	}
+	// 使用底层适配器的上传校验, 并经过观测装饰器
+	if len(observer.spans) != 1 || observer.spans[0] != (recordedSpan{operation: OperationSave, path: "a.txt", bytes: 7}) {
+		t.Fatalf("got spans %+v, want one save of 7 bytes", observer.spans)
+	}
+	var stored Checksums
+	if err = local.(*LocalAdapter).checksums().load("a.txt", &stored); err != nil || stored != sums {
+		t.Fatalf("stored checksums %+v, %v, want %+v", stored, err, sums)
+	}
+}
@@ -0,0 +1,193 @@
+package adapter
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/dysodeng/filesystem/storage"
+)
+
+// visibilitySidPrefix 由SetVisibility写入的存储桶策略语句Sid前缀
+const visibilitySidPrefix = "FilesystemVisibility"
+
+// bucketPolicy S3存储桶策略, 仅解析匿名读取相关的语句, 其余内容原样保留
+type bucketPolicy struct {
+	document   map[string]json.RawMessage
+	statements []json.RawMessage
+}
+
+type policyStatement struct {
+	Sid       string          `json:"Sid,omitempty"`
+	Effect    string          `json:"Effect"`
+	Principal json.RawMessage `json:"Principal"`
+	Action    policyValues    `json:"Action"`
+	Resource  policyValues    `json:"Resource"`
+	Condition json.RawMessage `json:"Condition,omitempty"`
+}
+
+// policyValues 策略中可为字符串或字符串数组的字段
+type policyValues []string
+
+func (values *policyValues) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*values = policyValues{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*values = multiple
+	return nil
+}
+
+// parseBucketPolicy 解析存储桶策略, 空策略返回空文档
+func parseBucketPolicy(policy string) (*bucketPolicy, error) {
+	result := &bucketPolicy{document: map[string]json.RawMessage{}}
+	if strings.TrimSpace(policy) == "" {
+		return result, nil
+	}
+	if err := json.Unmarshal([]byte(policy), &result.document); err != nil {
+		return nil, err
+	}
+	if statements, ok := result.document["Statement"]; ok {
+		if err := json.Unmarshal(statements, &result.statements); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// objectResource 对象的策略资源名, 以 / 结尾的路径视为前缀
+func objectResource(bucket, path string) string {
+	path = strings.TrimLeft(path, "/")
+	if strings.HasSuffix(path, "/") {
+		path += "*"
+	}
+	return "arn:aws:s3:::" + bucket + "/" + path
+}
+
+// visibility 计算资源的匿名访问可见性, 显式拒绝优先, 未匹配任何语句时为私有
+func (policy *bucketPolicy) visibility(resource string) storage.Visibility {
+	allowed := false
+	for _, raw := range policy.statements {
+		var statement policyStatement
+		if json.Unmarshal(raw, &statement) != nil || len(statement.Condition) > 0 || !anonymousPrincipal(statement.Principal) {
+			continue
+		}
+		if !matchAny(statement.Action, "s3:GetObject") || !matchAny(statement.Resource, resource) {
+			continue
+		}
+		if strings.EqualFold(statement.Effect, "Deny") {
+			return storage.VisibilityPrivate
+		}
+		allowed = allowed || strings.EqualFold(statement.Effect, "Allow")
+	}
+	if allowed {
+		return storage.VisibilityPublic
+	}
+	return storage.VisibilityPrivate
+}
+
+// set 设置资源的匿名读取权限, 替换此前为该资源写入的语句, 已满足时不新增语句
+func (policy *bucketPolicy) set(resource string, visibility storage.Visibility) {
+	sum := sha1.Sum([]byte(resource))
+	sid := visibilitySidPrefix + hex.EncodeToString(sum[:8])
+
+	statements := policy.statements[:0]
+	for _, raw := range policy.statements {
+		var statement policyStatement
+		if json.Unmarshal(raw, &statement) == nil && statement.Sid == sid {
+			continue
+		}
+		statements = append(statements, raw)
+	}
+	policy.statements = statements
+
+	if policy.visibility(resource) == visibility {
+		return
+	}
+
+	effect := "Allow"
+	if visibility == storage.VisibilityPrivate {
+		effect = "Deny"
+	}
+	statement, _ := json.Marshal(map[string]any{
+		"Sid":       sid,
+		"Effect":    effect,
+		"Principal": map[string][]string{"AWS": {"*"}},
+		"Action":    []string{"s3:GetObject"},
+		"Resource":  []string{resource},
+	})
+	policy.statements = append(policy.statements, statement)
+}
+
+// String 序列化存储桶策略, 没有语句时返回空字符串表示删除策略
+func (policy *bucketPolicy) String() string {
+	if len(policy.statements) == 0 {
+		return ""
+	}
+	if _, ok := policy.document["Version"]; !ok {
+		policy.document["Version"] = json.RawMessage(`"2012-10-17"`)
+	}
+	statements, _ := json.Marshal(policy.statements)
+	policy.document["Statement"] = statements
+	document, _ := json.Marshal(policy.document)
+	return string(document)
+}
+
+// anonymousPrincipal 是否为匿名用户: "*", {"AWS":"*"} 或 {"AWS":["*"]}
+func anonymousPrincipal(principal json.RawMessage) bool {
+	var single string
+	if json.Unmarshal(principal, &single) == nil {
+		return single == "*"
+	}
+	var principals map[string]policyValues
+	if json.Unmarshal(principal, &principals) != nil {
+		return false
+	}
+	for _, value := range principals["AWS"] {
+		if value == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny 是否匹配任一通配符模式
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if wildcardMatch(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardMatch 策略通配符匹配, * 匹配任意字符(含 /), ? 匹配单个字符
+func wildcardMatch(pattern, value string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(value); i >= 0; i-- {
+				if wildcardMatch(pattern[1:], value[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(value) == 0 {
+				return false
+			}
+		default:
+			if len(value) == 0 || pattern[0] != value[0] {
+				return false
+			}
+		}
+		pattern, value = pattern[1:], value[1:]
+	}
+	return len(value) == 0
+}
@@ -0,0 +1,273 @@
+package adapter
+
+import (
+	"io"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+// RetryAdapter 失败重试装饰器
+// 对幂等操作(Info, Read, HasFile, List, Delete, MultipleDelete, Copy)按指数退避加随机抖动重试,
+// Save 仅在源内容可Seek时重试
+type RetryAdapter struct {
+	adapter Adapter
+	config  RetryConfig
+}
+
+type RetryConfig struct {
+	MaxAttempts int                  // 最大尝试次数(含首次), 默认3
+	BaseDelay   time.Duration        // 首次重试等待时间, 默认100ms
+	MaxDelay    time.Duration        // 最大等待时间, 默认5s
+	Retryable   func(err error) bool // 是否可重试, 默认IsRetryable
+}
+
+// IsRetryable 是否为可重试的临时性错误(限流、服务不可用、超时、网络错误)
+func IsRetryable(err error) bool {
+	return ClassifyError(err).retryable()
+}
+
+// retryable 该类错误是否为临时性错误
+func (kind ErrorKind) retryable() bool {
+	switch kind {
+	case ErrorKindThrottled, ErrorKindUnavailable, ErrorKindTimeout, ErrorKindNetwork:
+		return true
+	}
+	return false
+}
+
+func NewRetryAdapter(adapter Adapter, config RetryConfig) Adapter {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.BaseDelay <= 0 {
+		config.BaseDelay = 100 * time.Millisecond
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = 5 * time.Second
+	}
+	if config.Retryable == nil {
+		config.Retryable = IsRetryable
+	}
+	return &RetryAdapter{
+		adapter: adapter,
+		config:  config,
+	}
+}
+
+// Unwrap 返回被装饰的适配器
+func (adapter *RetryAdapter) Unwrap() Adapter {
+	return adapter.adapter
+}
+
+// backoff 第attempt次重试前的等待时间, 全抖动指数退避
+func (adapter *RetryAdapter) backoff(attempt int) time.Duration {
+	delay := adapter.config.BaseDelay << attempt
+	if delay <= 0 || delay > adapter.config.MaxDelay {
+		delay = adapter.config.MaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(delay) + 1))
+}
+
+// do 执行操作, 可重试错误按退避策略重试
+func (adapter *RetryAdapter) do(fn func() error) error {
+	var err error
+	for attempt := 0; attempt < adapter.config.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(adapter.backoff(attempt - 1))
+		}
+		if err = fn(); err == nil || !adapter.config.Retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (adapter *RetryAdapter) Info(file string) (storage.Attribute, error) {
+	var attribute storage.Attribute
+	err := adapter.do(func() (err error) {
+		attribute, err = adapter.adapter.Info(file)
+		return err
+	})
+	return attribute, err
+}
+
+// HasFile 通过Info判断, 以便识别可重试错误
+func (adapter *RetryAdapter) HasFile(file string) bool {
+	attribute, err := adapter.Info(file)
+	if err != nil {
+		return false
+	}
+	return attribute.IsFile()
+}
+
+func (adapter *RetryAdapter) HasDir(file string) bool {
+	return adapter.adapter.HasDir(file)
+}
+
+func (adapter *RetryAdapter) Read(file string) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	err := adapter.do(func() (err error) {
+		reader, err = adapter.adapter.Read(file)
+		return err
+	})
+	return reader, err
+}
+
+// Save 源内容实现io.Seeker时失败后回到起始位置重试, 否则只尝试一次
+func (adapter *RetryAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (bool, error) {
+	var result bool
+	err := adapter.upload(srcFile, func() (err error) {
+		result, err = adapter.adapter.Save(dstFile, srcFile, mimeType)
+		return err
+	})
+	return result, err
+}
+
+// SaveEncoded 转发至底层适配器, 重试规则同 Save
+func (adapter *RetryAdapter) SaveEncoded(dstFile string, srcFile io.Reader, mimeType string, encoding ContentEncoding, size int64) error {
+	encoder := contentEncoder(adapter.adapter)
+	if encoder == nil {
+		return errors.Wrap(CompressionUnsupported, "content encoding")
+	}
+	return adapter.upload(srcFile, func() error {
+		return encoder.SaveEncoded(dstFile, srcFile, mimeType, encoding, size)
+	})
+}
+
+// SaveWithChecksum 转发至底层适配器的上传校验, 重试规则同 Save
+func (adapter *RetryAdapter) SaveWithChecksum(dstFile string, srcFile io.Reader, mimeType string) (Checksums, error) {
+	saver := checksumSaver(adapter.adapter)
+	if saver == nil {
+		return Checksums{}, ChecksumUnsupported
+	}
+	var sums Checksums
+	err := adapter.upload(srcFile, func() (err error) {
+		sums, err = saver.SaveWithChecksum(dstFile, srcFile, mimeType)
+		return err
+	})
+	return sums, err
+}
+
+// upload 源内容实现io.Seeker时失败后回到起始位置重试, 否则只尝试一次
+func (adapter *RetryAdapter) upload(srcFile io.Reader, fn func() error) error {
+	seeker, ok := srcFile.(io.Seeker)
+	if !ok {
+		return fn()
+	}
+
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fn()
+	}
+
+	first := true
+	return adapter.do(func() error {
+		if !first {
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		first = false
+		return fn()
+	})
+}
+
+func (adapter *RetryAdapter) Cover(sourceImagePath, coverImagePath string, width, height uint) error {
+	return adapter.adapter.Cover(sourceImagePath, coverImagePath, width, height)
+}
+
+func (adapter *RetryAdapter) Copy(srcFile, dstFile string) (bool, error) {
+	var result bool
+	err := adapter.do(func() (err error) {
+		result, err = adapter.adapter.Copy(srcFile, dstFile)
+		return err
+	})
+	return result, err
+}
+
+func (adapter *RetryAdapter) Move(dstFile, srcFile string) (bool, error) {
+	return adapter.adapter.Move(dstFile, srcFile)
+}
+
+func (adapter *RetryAdapter) Delete(file string) (bool, error) {
+	var result bool
+	err := adapter.do(func() (err error) {
+		result, err = adapter.adapter.Delete(file)
+		return err
+	})
+	return result, err
+}
+
+func (adapter *RetryAdapter) MultipleDelete(fileList []string) (bool, error) {
+	var result bool
+	err := adapter.do(func() (err error) {
+		result, err = adapter.adapter.MultipleDelete(fileList)
+		return err
+	})
+	return result, err
+}
+
+// CopyFrom 转发至底层适配器的跨存储桶服务端复制, 重试规则同 Copy
+func (adapter *RetryAdapter) CopyFrom(src Adapter, srcFile, dstFile string) error {
+	copier := bucketCopier(adapter.adapter)
+	if copier == nil {
+		return CrossBucketUnsupported
+	}
+	return adapter.do(func() error {
+		return copier.CopyFrom(src, srcFile, dstFile)
+	})
+}
+
+// DeleteObjects 转发至底层适配器的原生批量删除, 请求整体失败时重试
+func (adapter *RetryAdapter) DeleteObjects(fileList []string) ([]BatchResult, error) {
+	deleter := batchDeleter(adapter.adapter)
+	if deleter == nil {
+		return nil, BatchDeleteUnsupported
+	}
+	var results []BatchResult
+	err := adapter.do(func() (err error) {
+		results, err = deleter.DeleteObjects(fileList)
+		return err
+	})
+	return results, err
+}
+
+func (adapter *RetryAdapter) MkDir(dir string, mode os.FileMode) (bool, error) {
+	return adapter.adapter.MkDir(dir, mode)
+}
+
+func (adapter *RetryAdapter) DeleteDir(dir string) (bool, error) {
+	return adapter.adapter.DeleteDir(dir)
+}
+
+// List 每次尝试先缓存结果, 成功后再回调, 避免重试导致重复回调
+func (adapter *RetryAdapter) List(dir string, iterable func(attribute storage.Attribute)) error {
+	var attributes []storage.Attribute
+	err := adapter.do(func() error {
+		attributes = attributes[:0]
+		return adapter.adapter.List(dir, func(attribute storage.Attribute) {
+			attributes = append(attributes, attribute)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, attribute := range attributes {
+		iterable(attribute)
+	}
+
+	return nil
+}
+
+func (adapter *RetryAdapter) FullPath(path string) string {
+	return adapter.adapter.FullPath(path)
+}
+
+func (adapter *RetryAdapter) OriginalPath(fullPath string) string {
+	return adapter.adapter.OriginalPath(fullPath)
+}
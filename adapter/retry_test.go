@@ -0,0 +1,155 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/huaweicloud/huaweicloud-sdk-go-obs/obs"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+	"github.com/tencentyun/cos-go-sdk-v5"
+)
+
+// timeoutError 超时的网络错误
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		kind      ErrorKind
+		retryable bool
+	}{
+		{name: "nil", kind: ErrorKindNone},
+		{name: "wrapped not exists", err: errors.Wrap(FileNotExists, "a.txt"), kind: ErrorKindNotFound},
+		{name: "os permission", err: &os.PathError{Op: "open", Path: "a.txt", Err: os.ErrPermission}, kind: ErrorKindForbidden},
+		{name: "config", err: &ConfigError{Driver: "oss", Field: "EndPoint", Reason: "is required"}, kind: ErrorKindInvalid},
+		{name: "deadline", err: context.DeadlineExceeded, kind: ErrorKindTimeout, retryable: true},
+		{name: "canceled", err: context.Canceled, kind: ErrorKindCanceled},
+		{name: "minio throttled", err: minio.ErrorResponse{StatusCode: 503, Code: "SlowDown"}, kind: ErrorKindThrottled, retryable: true},
+		{name: "minio access denied", err: minio.ErrorResponse{StatusCode: 403, Code: "AccessDenied"}, kind: ErrorKindForbidden},
+		{name: "oss internal error", err: oss.ServiceError{StatusCode: 500, Code: "InternalError"}, kind: ErrorKindUnavailable, retryable: true},
+		{name: "oss bad signature", err: oss.ServiceError{StatusCode: 403, Code: "SignatureDoesNotMatch"}, kind: ErrorKindUnauthorized},
+		{name: "cos status only", err: &cos.ErrorResponse{Response: &http.Response{StatusCode: 429}}, kind: ErrorKindThrottled, retryable: true},
+		{name: "obs no such key", err: obs.ObsError{BaseModel: obs.BaseModel{StatusCode: 404}, Code: "NoSuchKey"}, kind: ErrorKindNotFound},
+		{name: "obs bad request", err: obs.ObsError{BaseModel: obs.BaseModel{StatusCode: 400}}, kind: ErrorKindInvalid},
+		{name: "net timeout", err: &net.OpError{Op: "read", Err: timeoutError{}}, kind: ErrorKindTimeout, retryable: true},
+		{name: "connection reset", err: os.NewSyscallError("read", syscall.ECONNRESET), kind: ErrorKindNetwork, retryable: true},
+		{name: "unexpected eof", err: errors.Wrap(io.ErrUnexpectedEOF, "read body"), kind: ErrorKindNetwork, retryable: true},
+		{name: "unknown", err: errors.New("boom"), kind: ErrorKindUnknown},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if kind := ClassifyError(test.err); kind != test.kind {
+				t.Errorf("got kind %q, want %q", kind, test.kind)
+			}
+			if retryable := IsRetryable(test.err); retryable != test.retryable {
+				t.Errorf("got retryable %v, want %v", retryable, test.retryable)
+			}
+		})
+	}
+}
+
+// flakyAdapter 前 failures 次调用返回 err, 之后成功
+type flakyAdapter struct {
+	Adapter
+	err      error
+	failures int
+	calls    int
+	saved    []string // 每次 Save 读到的内容
+}
+
+func (flaky *flakyAdapter) fail() error {
+	flaky.calls++
+	if flaky.calls <= flaky.failures {
+		return flaky.err
+	}
+	return nil
+}
+
+func (flaky *flakyAdapter) Info(file string) (storage.Attribute, error) {
+	if err := flaky.fail(); err != nil {
+		return nil, err
+	}
+	return storage.NewFileAttribute(file, file, storage.VisibilityPrivate, "text/plain", 0, 0), nil
+}
+
+func (flaky *flakyAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (bool, error) {
+	content, err := io.ReadAll(srcFile)
+	if err != nil {
+		return false, err
+	}
+	flaky.saved = append(flaky.saved, string(content))
+	if err = flaky.fail(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func TestRetryAdapterRetries(t *testing.T) {
+	config := RetryConfig{MaxAttempts: 3, BaseDelay: time.Microsecond, MaxDelay: time.Microsecond}
+	transient := syscall.ECONNRESET
+
+	tests := []struct {
+		name      string
+		err       error
+		failures  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "recovers from transient errors", err: transient, failures: 2, wantCalls: 3},
+		{name: "gives up after max attempts", err: transient, failures: 5, wantCalls: 3, wantErr: true},
+		{name: "does not retry permanent errors", err: FileNotExists, failures: 1, wantCalls: 1, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flaky := &flakyAdapter{err: test.err, failures: test.failures}
+			_, err := NewRetryAdapter(flaky, config).Info("a.txt")
+			if (err != nil) != test.wantErr {
+				t.Errorf("got error %v, want error %v", err, test.wantErr)
+			}
+			if flaky.calls != test.wantCalls {
+				t.Errorf("called %d times, want %d", flaky.calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryAdapterSaveRewindsSeekableSource(t *testing.T) {
+	config := RetryConfig{MaxAttempts: 3, BaseDelay: time.Microsecond, MaxDelay: time.Microsecond}
+
+	// 可Seek的源内容从原始位置重新上传
+	flaky := &flakyAdapter{err: syscall.ECONNRESET, failures: 1}
+	source := bytes.NewReader([]byte("skip content"))
+	if _, err := source.Seek(5, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRetryAdapter(flaky, config).Save("a.txt", source, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if len(flaky.saved) != 2 || flaky.saved[0] != "content" || flaky.saved[1] != "content" {
+		t.Errorf("got uploads %q, want content twice", flaky.saved)
+	}
+
+	// 不可Seek的源内容只尝试一次
+	flaky = &flakyAdapter{err: syscall.ECONNRESET, failures: 1}
+	if _, err := NewRetryAdapter(flaky, config).Save("a.txt", io.MultiReader(bytes.NewReader([]byte("content"))), "text/plain"); err == nil {
+		t.Fatal("save of a non-seekable source was retried")
+	}
+	if flaky.calls != 1 {
+		t.Errorf("called %d times, want 1", flaky.calls)
+	}
+}
@@ -0,0 +1,182 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+// sidecarsDir 旁路元数据目录, 按 .sidecars/文件路径+后缀 保存, 不与用户文件同名冲突, 列举时整体隐藏
+const sidecarsDir = ".sidecars"
+
+// sidecar 旁路元数据, 以JSON文件保存, 供装饰器记录后端无关的文件元信息
+type sidecar struct {
+	adapter Adapter
+	suffix  string
+	dir     string // 元数据文件集中保存的目录, 按原文件路径存放, 为空时保存在原文件旁
+}
+
+// path 元数据文件路径
+func (s sidecar) path(file string) string {
+	if s.dir != "" {
+		return s.dir + "/" + strings.TrimLeft(file, "/") + s.suffix
+	}
+	return file + s.suffix
+}
+
+// is 是否为元数据文件
+func (s sidecar) is(path string) bool {
+	path = strings.TrimRight(path, "/")
+	if s.dir != "" && !strings.HasPrefix(strings.TrimLeft(path, "/"), s.dir+"/") {
+		return false
+	}
+	return strings.HasSuffix(path, s.suffix)
+}
+
+// file 由元数据文件路径得到原文件路径, 保留路径开头的 /
+func (s sidecar) file(path string) string {
+	file := strings.TrimLeft(path, "/")
+	prefix := path[:len(path)-len(file)]
+	if s.dir != "" {
+		if file == s.dir {
+			file = ""
+		}
+		file = strings.TrimPrefix(file, s.dir+"/")
+	}
+	return prefix + strings.TrimSuffix(file, s.suffix)
+}
+
+// hidden 列举 dir 时是否隐藏 path, 集中保存时隐藏元数据目录, 否则隐藏带后缀的元数据文件
+func (s sidecar) hidden(dir, path string) bool {
+	if s.dir != "" {
+		return sidecarHidden(dir, path)
+	}
+	return s.is(path)
+}
+
+// inSidecars 路径是否位于元数据目录内
+func inSidecars(path string) bool {
+	path = strings.Trim(path, "/")
+	return path == sidecarsDir || strings.HasPrefix(path, sidecarsDir+"/")
+}
+
+// sidecarHidden 列举 dir 时是否隐藏 path
+// 列举普通目录时隐藏元数据目录; 上层装饰器列举元数据目录时, 其中的元数据文件正是上层的文件,
+// 只隐藏下层为这些文件保存的元数据, 即再嵌套一层的元数据目录
+func sidecarHidden(dir, path string) bool {
+	for inSidecars(dir) && inSidecars(path) {
+		dir = strings.TrimPrefix(strings.TrimPrefix(strings.Trim(dir, "/"), sidecarsDir), "/")
+		path = strings.TrimPrefix(strings.TrimPrefix(strings.Trim(path, "/"), sidecarsDir), "/")
+	}
+	return inSidecars(path)
+}
+
+// load 读取元数据, 不存在时返回FileNotExists, 其余错误原样返回
+func (s sidecar) load(file string, v any) error {
+	reader, err := s.adapter.Read(s.path(file))
+	if err != nil {
+		return notExists(err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, v)
+}
+
+// save 保存元数据
+func (s sidecar) save(file string, v any) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.dir != "" {
+		if _, err = s.adapter.MkDir(path.Dir(s.path(file)), 0755); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.adapter.Save(s.path(file), bytes.NewReader(content), "application/json")
+	return err
+}
+
+// delete 删除元数据, 不存在时忽略
+func (s sidecar) delete(file string) error {
+	if _, err := s.adapter.Delete(s.path(file)); err != nil && !errors.Is(notExists(err), FileNotExists) {
+		return err
+	}
+	return nil
+}
+
+// copy 复制元数据, 源文件没有元数据时删除目标文件的旧元数据
+func (s sidecar) copy(srcFile, dstFile string) error {
+	// 集中保存时目标文件对应的元数据目录可能尚未创建, 复制失败会被误判为源文件没有元数据
+	if s.dir != "" && s.adapter.HasFile(s.path(srcFile)) {
+		if _, err := s.adapter.MkDir(path.Dir(s.path(dstFile)), 0755); err != nil {
+			return err
+		}
+	}
+	_, err := s.adapter.Copy(s.path(srcFile), s.path(dstFile))
+	if errors.Is(notExists(err), FileNotExists) {
+		return s.delete(dstFile)
+	}
+	return err
+}
+
+// attach 数据文件写入后保存元数据, 失败时删除数据文件及覆盖前的元数据, 以免留下无法还原的数据
+func (s sidecar) attach(file string, v any) error {
+	if err := s.save(file, v); err != nil {
+		_, _ = s.adapter.Delete(file)
+		_ = s.delete(file)
+		return err
+	}
+	return nil
+}
+
+// remove 删除数据文件及其元数据
+func (s sidecar) remove(file string) error {
+	if _, err := s.adapter.Delete(file); err != nil {
+		return err
+	}
+	return s.delete(file)
+}
+
+// withRecords 在文件列表后追加存在的元数据文件, 供批量删除使用
+func (s sidecar) withRecords(fileList []string) []string {
+	files := make([]string, 0, len(fileList)*2)
+	for _, file := range fileList {
+		files = append(files, file)
+		if s.adapter.HasFile(s.path(file)) {
+			files = append(files, s.path(file))
+		}
+	}
+	return files
+}
+
+// list 列举目录并隐藏元数据文件, 文件属性经logical转换后返回
+func (s sidecar) list(dir string, iterable func(attribute storage.Attribute), logical func(storage.Attribute) (storage.Attribute, error)) error {
+	var listErr error
+	err := s.adapter.List(dir, func(attribute storage.Attribute) {
+		if listErr != nil || s.hidden(dir, attribute.Path()) {
+			return
+		}
+		attribute, listErr = logical(attribute)
+		if listErr == nil {
+			iterable(attribute)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return listErr
+}
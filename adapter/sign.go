@@ -0,0 +1,57 @@
+package adapter
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var SignUnsupported = errors.New("signed url is not supported by the adapter")
+
+// maxSignExpires 预签名URL最长有效期, 取各云存储的公共上限
+const maxSignExpires = 7 * 24 * time.Hour
+
+// SignAdapter 支持生成预签名URL的存储适配器
+type SignAdapter interface {
+	// SignURL 生成限时访问的预签名URL, 私有存储桶同样可用
+	// @param path string 文件路径
+	// @param method string HTTP方法, 支持 GET、PUT、HEAD、DELETE
+	// @param expires time.Duration 有效期
+	SignURL(path, method string, expires time.Duration) (string, error)
+}
+
+// SignURL 生成预签名URL
+// 只跳过重试、观测装饰器, 加密、压缩等改写内容的装饰器下签名URL访问到的是改写后的内容, 返回 SignUnsupported
+// @param adapter Adapter 存储适配器
+// @param path string 文件路径
+// @param method string HTTP方法, 为空时为GET
+// @param expires time.Duration 有效期, 最长7天
+func SignURL(adapter Adapter, path, method string, expires time.Duration) (string, error) {
+	signer, ok := bareAdapter(adapter).(SignAdapter)
+	if !ok {
+		return "", SignUnsupported
+	}
+	method = strings.ToUpper(method)
+	switch method {
+	case "":
+		method = http.MethodGet
+	case http.MethodGet, http.MethodPut, http.MethodHead, http.MethodDelete:
+	default:
+		return "", errors.Errorf("unsupported sign method %q", method)
+	}
+	if expires < time.Second || expires > maxSignExpires {
+		return "", errors.Errorf("sign expires must be between 1s and %s", maxSignExpires)
+	}
+	return signer.SignURL(path, method, expires)
+}
+
+// SignURL 生成挂载点内文件的预签名URL
+func (manager *MountManager) SignURL(path, method string, expires time.Duration) (string, error) {
+	mp, err := manager.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	return SignURL(mp.adapter, mp.path, method, expires)
+}
@@ -0,0 +1,183 @@
+package adapter
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+var TaggingUnsupported = errors.New("tagging is not supported by the adapter")
+
+// tagsSuffix 本地存储标签文件后缀
+const tagsSuffix = ".fstags"
+
+// 对象标签限制, 取各云存储的公共上限
+const (
+	maxTags           = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+// TaggingAdapter 支持对象标签的存储适配器
+// 标签用于生命周期规则与费用分摊, 与自定义元数据不同, 修改标签不会重写对象
+type TaggingAdapter interface {
+	// PutTags 设置文件标签, 整体替换已有标签
+	// @param path string 文件路径
+	// @param tags map[string]string 标签
+	PutTags(path string, tags map[string]string) error
+
+	// GetTags 获取文件标签
+	// @param path string 文件路径
+	GetTags(path string) (map[string]string, error)
+
+	// DeleteTags 删除文件全部标签
+	// @param path string 文件路径
+	DeleteTags(path string) error
+}
+
+// PutTags 设置文件标签, 沿装饰器链查找TaggingAdapter
+// @param adapter Adapter 存储适配器
+// @param path string 文件路径
+// @param tags map[string]string 标签
+func PutTags(adapter Adapter, path string, tags map[string]string) error {
+	taggingAdapter, ok := As[TaggingAdapter](adapter)
+	if !ok {
+		return TaggingUnsupported
+	}
+	return taggingAdapter.PutTags(path, tags)
+}
+
+// GetTags 获取文件标签, 沿装饰器链查找TaggingAdapter
+// @param adapter Adapter 存储适配器
+// @param path string 文件路径
+func GetTags(adapter Adapter, path string) (map[string]string, error) {
+	taggingAdapter, ok := As[TaggingAdapter](adapter)
+	if !ok {
+		return nil, TaggingUnsupported
+	}
+	return taggingAdapter.GetTags(path)
+}
+
+// DeleteTags 删除文件全部标签, 沿装饰器链查找TaggingAdapter
+// @param adapter Adapter 存储适配器
+// @param path string 文件路径
+func DeleteTags(adapter Adapter, path string) error {
+	taggingAdapter, ok := As[TaggingAdapter](adapter)
+	if !ok {
+		return TaggingUnsupported
+	}
+	return taggingAdapter.DeleteTags(path)
+}
+
+// ListByTags 列出目录下标签匹配的文件, 目录不回调
+// 云存储列举接口不返回标签, 列举完成后按 options 的并发数逐个查询标签, 仍为每个文件一次请求;
+// 结果按列举顺序回调, 任一文件查询失败时返回该错误且不回调
+// @param adapter Adapter 存储适配器
+// @param dir string 目录
+// @param filter map[string]string 标签过滤条件, 需全部匹配, 值为空时仅要求存在该标签
+// @param options BatchOptions 查询标签的并发选项
+// @param iterable func 迭代器
+func ListByTags(adapter Adapter, dir string, filter map[string]string, options BatchOptions, iterable func(attribute storage.Attribute)) error {
+	taggingAdapter, ok := As[TaggingAdapter](adapter)
+	if !ok {
+		return TaggingUnsupported
+	}
+
+	var files []storage.Attribute
+	err := adapter.List(dir, func(attribute storage.Attribute) {
+		if !attribute.IsDir() {
+			files = append(files, attribute)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	matched := make([]bool, len(files))
+	errs := make([]error, len(files))
+	RunBatch(len(files), options, func(i int) {
+		tags, err := taggingAdapter.GetTags(files[i].Path())
+		if err != nil {
+			errs[i] = errors.Wrap(err, files[i].Path())
+			return
+		}
+		matched[i] = matchTags(tags, filter)
+	})
+	for _, err = range errs {
+		if err != nil {
+			return err
+		}
+	}
+	for i, attribute := range files {
+		if matched[i] {
+			iterable(attribute)
+		}
+	}
+	return nil
+}
+
+// matchTags 标签是否满足过滤条件
+func matchTags(tags, filter map[string]string) bool {
+	for key, value := range filter {
+		tag, ok := tags[key]
+		if !ok || (value != "" && tag != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// validTags 校验标签数量及键值长度
+func validTags(tags map[string]string) error {
+	if len(tags) > maxTags {
+		return errors.Errorf("too many tags: %d, at most %d", len(tags), maxTags)
+	}
+	for key, value := range tags {
+		if key == "" || utf8.RuneCountInString(key) > maxTagKeyLength {
+			return errors.Errorf("invalid tag key %q", key)
+		}
+		if utf8.RuneCountInString(value) > maxTagValueLength {
+			return errors.Errorf("tag value for key %q is too long", key)
+		}
+	}
+	return nil
+}
+
+// sortedTagKeys 按键排序, 保证请求体稳定
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PutTags 设置挂载点内文件标签
+func (manager *MountManager) PutTags(path string, tags map[string]string) error {
+	mp, err := manager.resolve(path)
+	if err != nil {
+		return err
+	}
+	return PutTags(mp.adapter, mp.path, tags)
+}
+
+// GetTags 获取挂载点内文件标签
+func (manager *MountManager) GetTags(path string) (map[string]string, error) {
+	mp, err := manager.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return GetTags(mp.adapter, mp.path)
+}
+
+// DeleteTags 删除挂载点内文件标签
+func (manager *MountManager) DeleteTags(path string) error {
+	mp, err := manager.resolve(path)
+	if err != nil {
+		return err
+	}
+	return DeleteTags(mp.adapter, mp.path)
+}
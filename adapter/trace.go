@@ -0,0 +1,51 @@
+package adapter
+
+// Tracer 链路追踪接口, 与OpenTelemetry的trace.Tracer对应, 便于桥接
+type Tracer interface {
+	// Start 开始一个Span
+	// @param name string Span名称, 如 filesystem.read
+	// @param attributes map[string]any 初始属性
+	Start(name string, attributes map[string]any) TraceSpan
+}
+
+// TraceSpan 链路追踪Span, 与OpenTelemetry的trace.Span对应
+type TraceSpan interface {
+	SetAttributes(attributes map[string]any)
+	RecordError(err error)
+	End()
+}
+
+// TraceObserver 链路追踪观察者, 每次存储操作生成一个Span
+type TraceObserver struct {
+	tracer Tracer
+}
+
+// NewTraceObserver 创建链路追踪观察者
+func NewTraceObserver(tracer Tracer) *TraceObserver {
+	return &TraceObserver{tracer: tracer}
+}
+
+func (observer *TraceObserver) StartSpan(backend, operation, path string) Span {
+	return &traceSpan{
+		span: observer.tracer.Start("filesystem."+operation, map[string]any{
+			"filesystem.backend":   backend,
+			"filesystem.operation": operation,
+			"filesystem.path":      path,
+		}),
+	}
+}
+
+type traceSpan struct {
+	span TraceSpan
+}
+
+func (span *traceSpan) End(bytes int64, err error) {
+	if bytes > 0 {
+		span.span.SetAttributes(map[string]any{"filesystem.bytes": bytes})
+	}
+	if err != nil {
+		span.span.SetAttributes(map[string]any{"error.kind": string(ClassifyError(err))})
+		span.span.RecordError(err)
+	}
+	span.span.End()
+}
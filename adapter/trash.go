@@ -0,0 +1,396 @@
+package adapter
+
+import (
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+var (
+	TrashUnsupported     = errors.New("trash is not supported by the adapter")
+	TrashRestoreConflict = errors.New("restore target already exists")
+)
+
+const (
+	defaultTrashPrefix = ".trash/"
+	trashSuffix        = ".fstrash"
+	trashIDLayout      = "20060102T150405.000000000Z"
+)
+
+// TrashAdapter 回收站装饰器
+// Delete 与 MultipleDelete 将文件移动到 <前缀><删除ID>/<原路径>, 删除记录保存在 .sidecars/<前缀><删除ID>.fstrash 中;
+// List 隐藏回收站前缀与元数据目录, 可通过 ListTrash 查看、Restore 恢复、EmptyTrash 清理。
+// DeleteDir 仍为永久删除。装饰器需位于装饰器链最外层, 以便移动时携带内层装饰器的元数据文件。
+type TrashAdapter struct {
+	adapter Adapter
+	config  TrashConfig
+	records sidecar
+}
+
+type TrashConfig struct {
+	Prefix string // 回收站路径前缀, 默认 .trash/
+}
+
+// TrashItem 回收站条目
+type TrashItem struct {
+	ID        string    // 删除ID
+	Path      string    // 原文件路径
+	TrashPath string    // 回收站内文件路径
+	Size      int64     // 文件大小
+	MimeType  string    // 文件类型
+	DeletedAt time.Time // 删除时间
+}
+
+// trashRecord 删除记录
+type trashRecord struct {
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	MimeType  string    `json:"mime_type"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func NewTrashAdapter(adapter Adapter, config TrashConfig) (Adapter, error) {
+	if config.Prefix == "" {
+		config.Prefix = defaultTrashPrefix
+	}
+	config.Prefix = strings.Trim(config.Prefix, "/")
+	if config.Prefix == "" {
+		return nil, &ConfigError{Driver: "trash", Field: "Prefix", Reason: "must not be the root directory"}
+	}
+	config.Prefix += "/"
+	return &TrashAdapter{
+		adapter: adapter,
+		config:  config,
+		records: sidecar{adapter: adapter, suffix: trashSuffix, dir: sidecarsDir},
+	}, nil
+}
+
+// Unwrap 返回被装饰的适配器
+func (adapter *TrashAdapter) Unwrap() Adapter {
+	return adapter.adapter
+}
+
+// inTrash 路径是否位于回收站内
+func (adapter *TrashAdapter) inTrash(file string) bool {
+	file = strings.TrimLeft(file, "/")
+	return file == strings.TrimSuffix(adapter.config.Prefix, "/") || strings.HasPrefix(file, adapter.config.Prefix)
+}
+
+// entry 删除ID对应的回收站条目路径, 不含记录文件后缀
+func (adapter *TrashAdapter) entry(id string) string {
+	return adapter.config.Prefix + id
+}
+
+// trashPath 回收站内文件路径
+func (adapter *TrashAdapter) trashPath(id, file string) string {
+	return adapter.entry(id) + "/" + strings.TrimLeft(file, "/")
+}
+
+// newID 按删除时间生成删除ID, 同一时刻重复删除时追加序号
+func (adapter *TrashAdapter) newID(deletedAt time.Time) string {
+	id := deletedAt.UTC().Format(trashIDLayout)
+	for i := 1; adapter.adapter.HasFile(adapter.records.path(adapter.entry(id))); i++ {
+		id = deletedAt.UTC().Format(trashIDLayout) + "-" + strconv.Itoa(i)
+	}
+	return id
+}
+
+// trash 将文件移入回收站
+func (adapter *TrashAdapter) trash(file string) error {
+	attribute, err := adapter.adapter.Info(file)
+	if err != nil {
+		return err
+	}
+	fileAttribute, ok := attribute.(*storage.FileAttribute)
+	if !ok {
+		return FileNotExists
+	}
+
+	deletedAt := time.Now()
+	id := adapter.newID(deletedAt)
+	dstFile := adapter.trashPath(id, file)
+	record := trashRecord{
+		Path:      file,
+		Size:      fileAttribute.FileSize(),
+		MimeType:  fileAttribute.MimeType(),
+		DeletedAt: deletedAt,
+	}
+
+	if _, err = adapter.adapter.MkDir(path.Dir(dstFile), 0755); err != nil {
+		return err
+	}
+	// 先写删除记录, 移动失败时回滚, 避免回收站中出现无记录的文件
+	if err = adapter.records.save(adapter.entry(id), record); err != nil {
+		adapter.prune(id, file)
+		return err
+	}
+	if _, err = adapter.adapter.Move(dstFile, file); err != nil {
+		_ = adapter.records.delete(adapter.entry(id))
+		adapter.prune(id, file)
+		return err
+	}
+	return nil
+}
+
+// prune 自下而上删除回收站条目中的空目录, 云存储上为空操作
+func (adapter *TrashAdapter) prune(id, file string) {
+	root := adapter.entry(id)
+	for dir := path.Dir(adapter.trashPath(id, file)); strings.HasPrefix(dir, root); dir = path.Dir(dir) {
+		if _, err := adapter.adapter.DeleteDir(dir); err != nil {
+			return
+		}
+	}
+}
+
+// ListTrash 列出回收站条目, 按删除时间倒序
+func (adapter *TrashAdapter) ListTrash() ([]TrashItem, error) {
+	prefix := strings.TrimSuffix(adapter.config.Prefix, "/")
+	recordsDir := sidecarsDir + "/" + prefix
+	if !adapter.adapter.HasDir(recordsDir) {
+		return nil, nil
+	}
+
+	var ids []string
+	err := adapter.adapter.List(recordsDir, func(attribute storage.Attribute) {
+		if attribute.IsDir() || sidecarHidden(recordsDir, attribute.Path()) || !adapter.records.is(attribute.Path()) {
+			return
+		}
+		if entry := strings.TrimLeft(adapter.records.file(attribute.Path()), "/"); path.Dir(entry) == prefix {
+			ids = append(ids, path.Base(entry))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]TrashItem, 0, len(ids))
+	for _, id := range ids {
+		var record trashRecord
+		if err = adapter.records.load(adapter.entry(id), &record); err != nil {
+			return nil, errors.Wrap(err, id)
+		}
+		items = append(items, TrashItem{
+			ID:        id,
+			Path:      record.Path,
+			TrashPath: adapter.trashPath(id, record.Path),
+			Size:      record.Size,
+			MimeType:  record.MimeType,
+			DeletedAt: record.DeletedAt,
+		})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	return items, nil
+}
+
+// Restore 将文件最近一次删除的版本恢复到原路径
+// @param file string 原文件路径
+func (adapter *TrashAdapter) Restore(file string) error {
+	items, err := adapter.ListTrash()
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if strings.TrimLeft(item.Path, "/") == strings.TrimLeft(file, "/") {
+			return adapter.restore(item)
+		}
+	}
+	return FileNotExists
+}
+
+func (adapter *TrashAdapter) restore(item TrashItem) error {
+	if adapter.adapter.HasFile(item.Path) {
+		return errors.Wrap(TrashRestoreConflict, item.Path)
+	}
+	if dir := path.Dir(item.Path); dir != "." && dir != "/" {
+		if _, err := adapter.adapter.MkDir(dir, 0755); err != nil {
+			return err
+		}
+	}
+	if _, err := adapter.adapter.Move(item.Path, item.TrashPath); err != nil {
+		return err
+	}
+	adapter.prune(item.ID, item.Path)
+	return adapter.records.delete(adapter.entry(item.ID))
+}
+
+// EmptyTrash 永久删除回收站中超过指定时长的条目, 返回删除的条目数
+// @param olderThan time.Duration 删除时长, 0为清空回收站
+func (adapter *TrashAdapter) EmptyTrash(olderThan time.Duration) (int, error) {
+	items, err := adapter.ListTrash()
+	if err != nil {
+		return 0, err
+	}
+
+	deadline := time.Now().Add(-olderThan)
+	purged := 0
+	for _, item := range items {
+		if item.DeletedAt.After(deadline) {
+			continue
+		}
+		if adapter.adapter.HasFile(item.TrashPath) {
+			if _, err = adapter.adapter.Delete(item.TrashPath); err != nil {
+				return purged, errors.Wrap(err, item.TrashPath)
+			}
+		}
+		adapter.prune(item.ID, item.Path)
+		if err = adapter.records.delete(adapter.entry(item.ID)); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (adapter *TrashAdapter) Info(file string) (storage.Attribute, error) {
+	return adapter.adapter.Info(file)
+}
+
+func (adapter *TrashAdapter) HasFile(file string) bool {
+	return adapter.adapter.HasFile(file)
+}
+
+func (adapter *TrashAdapter) HasDir(file string) bool {
+	return adapter.adapter.HasDir(file)
+}
+
+func (adapter *TrashAdapter) Read(file string) (io.ReadCloser, error) {
+	return adapter.adapter.Read(file)
+}
+
+func (adapter *TrashAdapter) Save(dstFile string, srcFile io.Reader, mimeType string) (bool, error) {
+	return adapter.adapter.Save(dstFile, srcFile, mimeType)
+}
+
+func (adapter *TrashAdapter) Cover(sourceImagePath, coverImagePath string, width, height uint) error {
+	return adapter.adapter.Cover(sourceImagePath, coverImagePath, width, height)
+}
+
+func (adapter *TrashAdapter) Copy(srcFile, dstFile string) (bool, error) {
+	return adapter.adapter.Copy(srcFile, dstFile)
+}
+
+func (adapter *TrashAdapter) Move(dstFile, srcFile string) (bool, error) {
+	return adapter.adapter.Move(dstFile, srcFile)
+}
+
+// Delete 将文件移入回收站, 回收站内的文件直接永久删除
+func (adapter *TrashAdapter) Delete(file string) (bool, error) {
+	if adapter.inTrash(file) {
+		return adapter.adapter.Delete(file)
+	}
+	if err := adapter.trash(file); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (adapter *TrashAdapter) MultipleDelete(fileList []string) (bool, error) {
+	for _, file := range fileList {
+		if _, err := adapter.Delete(file); err != nil {
+			return false, errors.Wrap(err, file)
+		}
+	}
+	return true, nil
+}
+
+func (adapter *TrashAdapter) MkDir(dir string, mode os.FileMode) (bool, error) {
+	return adapter.adapter.MkDir(dir, mode)
+}
+
+func (adapter *TrashAdapter) DeleteDir(dir string) (bool, error) {
+	return adapter.adapter.DeleteDir(dir)
+}
+
+// List 隐藏回收站目录与元数据目录
+func (adapter *TrashAdapter) List(dir string, iterable func(attribute storage.Attribute)) error {
+	return adapter.adapter.List(dir, func(attribute storage.Attribute) {
+		if !adapter.inTrash(attribute.Path()) && !sidecarHidden(dir, attribute.Path()) {
+			iterable(attribute)
+		}
+	})
+}
+
+func (adapter *TrashAdapter) FullPath(path string) string {
+	return adapter.adapter.FullPath(path)
+}
+
+func (adapter *TrashAdapter) OriginalPath(fullPath string) string {
+	return adapter.adapter.OriginalPath(fullPath)
+}
+
+// ListTrash 列出回收站条目, 沿装饰器链查找TrashAdapter
+// @param adapter Adapter 存储适配器
+func ListTrash(adapter Adapter) ([]TrashItem, error) {
+	trashAdapter, ok := As[*TrashAdapter](adapter)
+	if !ok {
+		return nil, TrashUnsupported
+	}
+	return trashAdapter.ListTrash()
+}
+
+// RestoreTrash 从回收站恢复文件, 沿装饰器链查找TrashAdapter
+// @param adapter Adapter 存储适配器
+// @param file string 原文件路径
+func RestoreTrash(adapter Adapter, file string) error {
+	trashAdapter, ok := As[*TrashAdapter](adapter)
+	if !ok {
+		return TrashUnsupported
+	}
+	return trashAdapter.Restore(file)
+}
+
+// EmptyTrash 清理回收站, 沿装饰器链查找TrashAdapter
+// @param adapter Adapter 存储适配器
+// @param olderThan time.Duration 删除时长, 0为清空回收站
+func EmptyTrash(adapter Adapter, olderThan time.Duration) (int, error) {
+	trashAdapter, ok := As[*TrashAdapter](adapter)
+	if !ok {
+		return 0, TrashUnsupported
+	}
+	return trashAdapter.EmptyTrash(olderThan)
+}
+
+// ListTrash 列出挂载点的回收站条目, 路径为挂载管理器路径
+// @param mount string 挂载点, 如 oss:// 或 前缀
+func (manager *MountManager) ListTrash(mount string) ([]TrashItem, error) {
+	mp, err := manager.resolve(mount)
+	if err != nil {
+		return nil, err
+	}
+	items, err := ListTrash(mp.adapter)
+	if err != nil || mp.mount == "" {
+		return items, err
+	}
+	for i := range items {
+		items[i].Path = mp.outer(items[i].Path)
+		items[i].TrashPath = mp.outer(items[i].TrashPath)
+	}
+	return items, nil
+}
+
+// RestoreTrash 从回收站恢复挂载点内的文件
+func (manager *MountManager) RestoreTrash(file string) error {
+	mp, err := manager.resolve(file)
+	if err != nil {
+		return err
+	}
+	return RestoreTrash(mp.adapter, mp.path)
+}
+
+// EmptyTrash 清理挂载点的回收站
+func (manager *MountManager) EmptyTrash(mount string, olderThan time.Duration) (int, error) {
+	mp, err := manager.resolve(mount)
+	if err != nil {
+		return 0, err
+	}
+	return EmptyTrash(mp.adapter, olderThan)
+}
@@ -0,0 +1,69 @@
+package adapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+func TestTrashAdapterRestore(t *testing.T) {
+	adapter, err := NewTrashAdapter(NewLocalAdapter(LocalConfig{BasePath: t.TempDir()}), TrashConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trash := adapter.(*TrashAdapter)
+
+	if _, err = trash.MkDir("docs", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range []string{"v1", "v2"} {
+		if _, err = trash.Save("docs/a.txt", bytes.NewReader([]byte(content)), "text/plain"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = trash.Delete("docs/a.txt"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if trash.HasFile("docs/a.txt") {
+		t.Fatal("deleted file still exists")
+	}
+	// 回收站内容与删除记录都不应出现在列举结果中
+	if err = trash.List("/", func(attribute storage.Attribute) {
+		if !attribute.IsDir() {
+			t.Errorf("listed %s", attribute.Path())
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := trash.ListTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Path != "docs/a.txt" || !items[0].DeletedAt.After(items[1].DeletedAt) {
+		t.Fatalf("got trash items %+v, want two deletions of docs/a.txt, newest first", items)
+	}
+
+	// 恢复最近一次删除的版本, 原路径已存在时不覆盖
+	if err = trash.Restore("docs/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := readAll(trash, "docs/a.txt"); err != nil || string(got) != "v2" {
+		t.Fatalf("restored %q, %v, want v2", got, err)
+	}
+	if err = trash.Restore("docs/a.txt"); !errors.Is(err, TrashRestoreConflict) {
+		t.Fatalf("restore over existing file: got %v, want TrashRestoreConflict", err)
+	}
+	if err = trash.Restore("docs/missing.txt"); !errors.Is(err, FileNotExists) {
+		t.Fatalf("restore missing file: got %v, want FileNotExists", err)
+	}
+
+	if purged, err := trash.EmptyTrash(0); err != nil || purged != 1 {
+		t.Fatalf("empty trash: got %d, %v, want 1", purged, err)
+	}
+	if items, err = trash.ListTrash(); err != nil || len(items) != 0 {
+		t.Fatalf("after empty trash: got %+v, %v", items, err)
+	}
+}
@@ -0,0 +1,289 @@
+package adapter
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+var (
+	FileAlreadyExists = errors.New("file already exists")
+	TreeIncomplete    = errors.New("tree operation partially failed")
+)
+
+// ConflictPolicy 目标文件已存在时的处理方式
+type ConflictPolicy string
+
+const (
+	ConflictOverwrite ConflictPolicy = "overwrite" // 覆盖目标文件
+	ConflictSkip      ConflictPolicy = "skip"      // 跳过该文件
+	ConflictFail      ConflictPolicy = "fail"      // 该文件记为失败
+)
+
+type TreeOptions struct {
+	Concurrency int                         // 并发数, 默认8
+	Conflict    ConflictPolicy              // 目标文件已存在时的处理方式, 默认覆盖
+	Progress    func(progress TreeProgress) // 进度回调, 每处理完一个文件调用一次, 不会并发调用
+}
+
+// TreeProgress 目录复制、移动进度
+type TreeProgress struct {
+	Src     string // 源文件路径
+	Dst     string // 目标文件路径
+	Done    int    // 已处理文件数
+	Total   int    // 文件总数
+	Skipped bool   // 是否因目标已存在而跳过
+	Err     error  // 失败原因
+}
+
+// TreeReport 目录复制、移动结果
+type TreeReport struct {
+	Total     int           // 文件总数
+	Succeeded int           // 成功文件数
+	Skipped   []string      // 因目标已存在而跳过的源文件
+	Failed    []BatchResult // 失败的源文件及原因
+}
+
+// treeEntry 目录树中的文件
+type treeEntry struct {
+	src string
+	dst string
+}
+
+// CopyTree 递归复制目录下的全部文件, 文件逐个调用 Copy, 云存储上为服务端复制
+// 部分文件失败时返回 TreeIncomplete, 失败明细见 TreeReport.Failed
+// @param adapter Adapter 存储适配器
+// @param srcDir string 源目录
+// @param dstDir string 目标目录
+// @param options TreeOptions 选项
+func CopyTree(adapter Adapter, srcDir, dstDir string, options TreeOptions) (TreeReport, error) {
+	return runTree(adapter, srcDir, adapter, dstDir, options, func(entry treeEntry) error {
+		_, err := adapter.Copy(entry.src, entry.dst)
+		return err
+	})
+}
+
+// MoveTree 递归移动目录下的全部文件, 跳过与失败的文件保留在源目录
+// @param adapter Adapter 存储适配器
+// @param srcDir string 源目录
+// @param dstDir string 目标目录
+// @param options TreeOptions 选项
+func MoveTree(adapter Adapter, srcDir, dstDir string, options TreeOptions) (TreeReport, error) {
+	report, err := runTree(adapter, srcDir, adapter, dstDir, options, func(entry treeEntry) error {
+		_, err := adapter.Move(entry.dst, entry.src)
+		return err
+	})
+	if err == nil || errors.Is(err, TreeIncomplete) {
+		PruneTree(adapter, srcDir)
+	}
+	return report, err
+}
+
+// runTree 遍历源目录, 创建目标目录后并发处理每个文件
+func runTree(src Adapter, srcDir string, dst Adapter, dstDir string, options TreeOptions, apply func(entry treeEntry) error) (TreeReport, error) {
+	srcDir, dstDir = strings.Trim(srcDir, "/"), strings.Trim(dstDir, "/")
+	if src == dst && (srcDir == "" || strings.HasPrefix(dstDir+"/", srcDir+"/")) {
+		return TreeReport{}, errors.Errorf("target directory %q is inside source directory %q", dstDir, srcDir)
+	}
+	if options.Conflict == "" {
+		options.Conflict = ConflictOverwrite
+	}
+
+	var entries []treeEntry
+	dirs := map[string]bool{dstDir: true}
+	err := Walk(src, srcDir, func(attribute storage.Attribute) {
+		rel := strings.TrimPrefix(strings.Trim(attribute.Path(), "/"), srcDir)
+		dst := path.Join(dstDir, strings.TrimLeft(rel, "/"))
+		if attribute.IsDir() {
+			dirs[dst] = true
+			return
+		}
+		dirs[path.Dir(dst)] = true
+		entries = append(entries, treeEntry{src: strings.Trim(attribute.Path(), "/"), dst: dst})
+	})
+	if err != nil {
+		return TreeReport{}, err
+	}
+
+	// 本地存储写入前需创建目标目录, 云存储上为空操作
+	for _, dir := range sortedDirs(dirs) {
+		if _, err = dst.MkDir(dir, 0755); err != nil {
+			return TreeReport{}, err
+		}
+	}
+
+	report := TreeReport{Total: len(entries)}
+	var mu sync.Mutex
+	RunBatch(len(entries), BatchOptions{Concurrency: options.Concurrency}, func(i int) {
+		entry := entries[i]
+		skipped := false
+		var err error
+		if options.Conflict != ConflictOverwrite && dst.HasFile(entry.dst) {
+			if options.Conflict == ConflictSkip {
+				skipped = true
+			} else {
+				err = errors.Wrap(FileAlreadyExists, entry.dst)
+			}
+		}
+		if !skipped && err == nil {
+			err = apply(entry)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err != nil:
+			report.Failed = append(report.Failed, BatchResult{Path: entry.src, Err: err})
+		case skipped:
+			report.Skipped = append(report.Skipped, entry.src)
+		default:
+			report.Succeeded++
+		}
+		if options.Progress != nil {
+			options.Progress(TreeProgress{
+				Src:     entry.src,
+				Dst:     entry.dst,
+				Done:    report.Succeeded + len(report.Skipped) + len(report.Failed),
+				Total:   report.Total,
+				Skipped: skipped,
+				Err:     err,
+			})
+		}
+	})
+
+	if len(report.Failed) > 0 {
+		return report, errors.Wrapf(TreeIncomplete, "%d of %d files failed", len(report.Failed), report.Total)
+	}
+	return report, nil
+}
+
+// Walk 递归遍历目录下的文件与子目录, 不包含目录自身
+// 本地存储 List 已递归返回全部子孙, 仅当子目录内容未被列出时才继续列举该子目录
+// @param adapter Adapter 存储适配器
+// @param dir string 目录路径
+// @param iterable func 迭代器
+func Walk(adapter Adapter, dir string, iterable func(attribute storage.Attribute)) error {
+	dir = strings.Trim(dir, "/")
+	var attributes []storage.Attribute
+	err := adapter.List(dir, func(attribute storage.Attribute) {
+		if strings.Trim(attribute.Path(), "/") != dir {
+			attributes = append(attributes, attribute)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]bool)
+	for _, attribute := range attributes {
+		listed[path.Dir(strings.Trim(attribute.Path(), "/"))] = true
+	}
+	for _, attribute := range attributes {
+		iterable(attribute)
+		current := strings.Trim(attribute.Path(), "/")
+		if attribute.IsDir() && !listed[current] {
+			if err = Walk(adapter, current, iterable); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PruneTree 自下而上删除目录及其下已清空的子目录, 非空目录删除失败时忽略
+// @param adapter Adapter 存储适配器
+// @param dir string 目录, 为空时只清理子目录
+func PruneTree(adapter Adapter, dir string) {
+	dir = strings.Trim(dir, "/")
+	dirs := map[string]bool{}
+	_ = Walk(adapter, dir, func(attribute storage.Attribute) {
+		if attribute.IsDir() {
+			dirs[strings.Trim(attribute.Path(), "/")] = true
+		}
+	})
+	sorted := sortedDirs(dirs)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		_, _ = adapter.DeleteDir(sorted[i])
+	}
+	if dir != "" {
+		_, _ = adapter.DeleteDir(dir)
+	}
+}
+
+// sortedDirs 目录按路径排序, 父目录在前
+func sortedDirs(dirs map[string]bool) []string {
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		if dir != "" && dir != "." {
+			sorted = append(sorted, dir)
+		}
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// CopyTree 递归复制挂载点内的目录, 跨挂载点时逐个文件流式复制
+// 结果中的路径为挂载管理器路径
+func (manager *MountManager) CopyTree(srcDir, dstDir string, options TreeOptions) (TreeReport, error) {
+	return manager.tree(srcDir, dstDir, options, false)
+}
+
+// MoveTree 递归移动挂载点内的目录, 跨挂载点时复制成功后删除源文件
+func (manager *MountManager) MoveTree(srcDir, dstDir string, options TreeOptions) (TreeReport, error) {
+	return manager.tree(srcDir, dstDir, options, true)
+}
+
+func (manager *MountManager) tree(srcDir, dstDir string, options TreeOptions, move bool) (TreeReport, error) {
+	src, err := manager.resolve(srcDir)
+	if err != nil {
+		return TreeReport{}, err
+	}
+	dst, err := manager.resolve(dstDir)
+	if err != nil {
+		return TreeReport{}, err
+	}
+	if progress := options.Progress; progress != nil {
+		options.Progress = func(p TreeProgress) {
+			p.Src, p.Dst = src.outer(p.Src), dst.outer(p.Dst)
+			progress(p)
+		}
+	}
+
+	var report TreeReport
+	switch {
+	case src.adapter == dst.adapter && move:
+		report, err = MoveTree(src.adapter, src.path, dst.path, options)
+	case src.adapter == dst.adapter:
+		report, err = CopyTree(src.adapter, src.path, dst.path, options)
+	default:
+		report, err = runTree(src.adapter, src.path, dst.adapter, dst.path, options, func(entry treeEntry) error {
+			if _, err := CopyAcross(src.adapter, entry.src, dst.adapter, entry.dst); err != nil || !move {
+				return err
+			}
+			_, err := src.adapter.Delete(entry.src)
+			return err
+		})
+		if move && (err == nil || errors.Is(err, TreeIncomplete)) {
+			PruneTree(src.adapter, src.path)
+		}
+	}
+	return src.rebaseReport(report), err
+}
+
+// rebaseReport 将结果中的源文件路径还原为挂载管理器路径
+func (mp mountPath) rebaseReport(report TreeReport) TreeReport {
+	if mp.mount == "" {
+		return report
+	}
+	for i := range report.Skipped {
+		report.Skipped[i] = mp.outer(report.Skipped[i])
+	}
+	for i := range report.Failed {
+		report.Failed[i].Path = mp.outer(report.Failed[i].Path)
+	}
+	return report
+}
@@ -0,0 +1,119 @@
+package adapter
+
+import (
+	"bytes"
+	"path"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// newTestTree 创建源目录 src 与已有部分同名文件的目标目录 dst
+func newTestTree(t *testing.T) Adapter {
+	t.Helper()
+	local := NewLocalAdapter(LocalConfig{BasePath: t.TempDir()})
+	files := map[string]string{
+		"src/a.txt":          "new",
+		"src/sub/b.txt":      "new",
+		"src/sub/deep/c.txt": "new",
+		"dst/a.txt":          "old",
+		"dst/sub/b.txt":      "old",
+	}
+	for file, content := range files {
+		if _, err := local.MkDir(path.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := local.Save(file, bytes.NewReader([]byte(content)), "text/plain"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return local
+}
+
+func TestCopyTreeConflictPolicies(t *testing.T) {
+	tests := []struct {
+		conflict      ConflictPolicy
+		wantSucceeded int
+		wantSkipped   []string
+		wantFailed    []string
+		wantContent   string // 冲突文件 dst/a.txt 的内容
+	}{
+		{conflict: ConflictOverwrite, wantSucceeded: 3, wantContent: "new"},
+		{conflict: ConflictSkip, wantSucceeded: 1, wantSkipped: []string{"src/a.txt", "src/sub/b.txt"}, wantContent: "old"},
+		{conflict: ConflictFail, wantSucceeded: 1, wantFailed: []string{"src/a.txt", "src/sub/b.txt"}, wantContent: "old"},
+	}
+	for _, test := range tests {
+		t.Run(string(test.conflict), func(t *testing.T) {
+			local := newTestTree(t)
+			var progress []TreeProgress
+			report, err := CopyTree(local, "src", "dst", TreeOptions{
+				Conflict: test.conflict,
+				Progress: func(p TreeProgress) { progress = append(progress, p) },
+			})
+			if len(test.wantFailed) > 0 != errors.Is(err, TreeIncomplete) {
+				t.Fatalf("got error %v", err)
+			}
+
+			var failed []string
+			for _, result := range report.Failed {
+				if !errors.Is(result.Err, FileAlreadyExists) {
+					t.Errorf("%s failed with %v, want FileAlreadyExists", result.Path, result.Err)
+				}
+				failed = append(failed, result.Path)
+			}
+			sort.Strings(failed)
+			sort.Strings(report.Skipped)
+			if report.Total != 3 || report.Succeeded != test.wantSucceeded ||
+				!slices.Equal(report.Skipped, test.wantSkipped) || !slices.Equal(failed, test.wantFailed) {
+				t.Errorf("got report %+v", report)
+			}
+			if len(progress) != 3 || progress[2].Done != 3 || progress[2].Total != 3 {
+				t.Errorf("got progress %+v, want one call per file", progress)
+			}
+
+			if got, err := readAll(local, "dst/a.txt"); err != nil || string(got) != test.wantContent {
+				t.Errorf("dst/a.txt: got %q, %v, want %q", got, err, test.wantContent)
+			}
+			if got, err := readAll(local, "dst/sub/deep/c.txt"); err != nil || string(got) != "new" {
+				t.Errorf("dst/sub/deep/c.txt: got %q, %v", got, err)
+			}
+			if !local.HasFile("src/a.txt") {
+				t.Error("copy removed the source")
+			}
+		})
+	}
+}
+
+func TestMoveTreeKeepsSkippedFiles(t *testing.T) {
+	local := newTestTree(t)
+	report, err := MoveTree(local, "src", "dst", TreeOptions{Conflict: ConflictSkip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Succeeded != 1 || len(report.Skipped) != 2 {
+		t.Fatalf("got report %+v", report)
+	}
+	// 跳过的文件保留在源目录, 已清空的子目录被删除
+	for file, want := range map[string]bool{
+		"src/a.txt":          true,
+		"src/sub/b.txt":      true,
+		"src/sub/deep/c.txt": false,
+		"dst/sub/deep/c.txt": true,
+	} {
+		if local.HasFile(file) != want {
+			t.Errorf("%s exists = %v, want %v", file, !want, want)
+		}
+	}
+	if local.HasDir("src/sub/deep") {
+		t.Error("emptied source directory was not pruned")
+	}
+}
+
+func TestCopyTreeIntoItself(t *testing.T) {
+	local := newTestTree(t)
+	if _, err := CopyTree(local, "src", "src/sub/copy", TreeOptions{}); err == nil {
+		t.Fatal("copy into a subdirectory of the source was allowed")
+	}
+}
@@ -0,0 +1,154 @@
+package adapter
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var VersioningUnsupported = errors.New("versioning is not supported by the adapter")
+
+// ObjectVersion 文件历史版本
+type ObjectVersion struct {
+	VersionID      string    // 版本ID
+	IsLatest       bool      // 是否为当前版本
+	IsDeleteMarker bool      // 是否为删除标记, 删除标记没有内容
+	Size           int64     // 文件大小
+	ETag           string    // 实体标签
+	LastModified   time.Time // 修改时间
+}
+
+// VersioningAdapter 支持多版本的存储适配器, 需存储桶已开启版本控制
+type VersioningAdapter interface {
+	// ListVersions 列出文件的全部版本, 按修改时间倒序
+	// @param path string 文件路径
+	ListVersions(path string) ([]ObjectVersion, error)
+
+	// ReadVersion 读取指定版本的文件内容
+	// @param path string 文件路径
+	// @param versionID string 版本ID
+	ReadVersion(path, versionID string) (io.ReadCloser, error)
+
+	// RestoreVersion 将指定版本恢复为当前版本, 当前版本保留为历史版本
+	// @param path string 文件路径
+	// @param versionID string 版本ID
+	RestoreVersion(path, versionID string) error
+
+	// DeleteVersion 永久删除指定版本
+	// @param path string 文件路径
+	// @param versionID string 版本ID
+	DeleteVersion(path, versionID string) error
+}
+
+// ListVersions 列出文件的全部版本, 沿装饰器链查找VersioningAdapter
+// @param adapter Adapter 存储适配器
+// @param path string 文件路径
+func ListVersions(adapter Adapter, path string) ([]ObjectVersion, error) {
+	versioningAdapter, ok := As[VersioningAdapter](adapter)
+	if !ok {
+		return nil, VersioningUnsupported
+	}
+	return versioningAdapter.ListVersions(path)
+}
+
+// ReadVersion 读取指定版本的文件内容
+// 加密、压缩装饰器的旁路信息不随版本保留, 经过这类装饰器时不支持读取历史版本
+// @param adapter Adapter 存储适配器
+// @param path string 文件路径
+// @param versionID string 版本ID
+func ReadVersion(adapter Adapter, path, versionID string) (io.ReadCloser, error) {
+	versioningAdapter, ok := As[VersioningAdapter](adapter)
+	if !ok || transformsContent(adapter) {
+		return nil, VersioningUnsupported
+	}
+	return versioningAdapter.ReadVersion(path, versionID)
+}
+
+// RestoreVersion 将指定版本恢复为当前版本
+// @param adapter Adapter 存储适配器
+// @param path string 文件路径
+// @param versionID string 版本ID
+func RestoreVersion(adapter Adapter, path, versionID string) error {
+	versioningAdapter, ok := As[VersioningAdapter](adapter)
+	if !ok || transformsContent(adapter) {
+		return VersioningUnsupported
+	}
+	return versioningAdapter.RestoreVersion(path, versionID)
+}
+
+// DeleteVersion 永久删除指定版本
+// @param adapter Adapter 存储适配器
+// @param path string 文件路径
+// @param versionID string 版本ID
+func DeleteVersion(adapter Adapter, path, versionID string) error {
+	versioningAdapter, ok := As[VersioningAdapter](adapter)
+	if !ok {
+		return VersioningUnsupported
+	}
+	return versioningAdapter.DeleteVersion(path, versionID)
+}
+
+// transformsContent 装饰器链中是否有改写文件内容的装饰器
+func transformsContent(adapter Adapter) bool {
+	for adapter != nil {
+		switch adapter.(type) {
+		case *EncryptedAdapter, *CompressedAdapter:
+			return true
+		}
+		wrapper, ok := adapter.(Wrapper)
+		if !ok {
+			break
+		}
+		adapter = wrapper.Unwrap()
+	}
+	return false
+}
+
+// sortVersions 按修改时间倒序, 当前版本优先
+func sortVersions(versions []ObjectVersion) []ObjectVersion {
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].IsLatest != versions[j].IsLatest {
+			return versions[i].IsLatest
+		}
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+	return versions
+}
+
+// ListVersions 列出挂载点内文件的全部版本
+func (manager *MountManager) ListVersions(path string) ([]ObjectVersion, error) {
+	mp, err := manager.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return ListVersions(mp.adapter, mp.path)
+}
+
+// ReadVersion 读取挂载点内文件的指定版本
+func (manager *MountManager) ReadVersion(path, versionID string) (io.ReadCloser, error) {
+	mp, err := manager.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return ReadVersion(mp.adapter, mp.path, versionID)
+}
+
+// RestoreVersion 恢复挂载点内文件的指定版本
+func (manager *MountManager) RestoreVersion(path, versionID string) error {
+	mp, err := manager.resolve(path)
+	if err != nil {
+		return err
+	}
+	return RestoreVersion(mp.adapter, mp.path, versionID)
+}
+
+// DeleteVersion 删除挂载点内文件的指定版本
+func (manager *MountManager) DeleteVersion(path, versionID string) error {
+	mp, err := manager.resolve(path)
+	if err != nil {
+		return err
+	}
+	return DeleteVersion(mp.adapter, mp.path, versionID)
+}
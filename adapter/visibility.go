@@ -0,0 +1,81 @@
+package adapter
+
+import (
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+var VisibilityUnsupported = errors.New("visibility is not supported by the adapter")
+
+// VisibilityAdapter 支持设置文件可见性的存储适配器
+type VisibilityAdapter interface {
+	// SetVisibility 设置文件可见性
+	// @param path string 文件路径
+	// @param visibility storage.Visibility 可见性
+	SetVisibility(path string, visibility storage.Visibility) error
+
+	// Visibility 获取文件可见性
+	// @param path string 文件路径
+	Visibility(path string) (storage.Visibility, error)
+}
+
+// SetVisibility 设置文件可见性, 沿装饰器链查找VisibilityAdapter
+// @param adapter Adapter 存储适配器
+// @param path string 文件路径
+// @param visibility storage.Visibility 可见性
+func SetVisibility(adapter Adapter, path string, visibility storage.Visibility) error {
+	if err := validVisibility(visibility); err != nil {
+		return err
+	}
+	visibilityAdapter, ok := As[VisibilityAdapter](adapter)
+	if !ok {
+		return VisibilityUnsupported
+	}
+	return visibilityAdapter.SetVisibility(path, visibility)
+}
+
+// GetVisibility 获取文件可见性, 沿装饰器链查找VisibilityAdapter
+// @param adapter Adapter 存储适配器
+// @param path string 文件路径
+func GetVisibility(adapter Adapter, path string) (storage.Visibility, error) {
+	visibilityAdapter, ok := As[VisibilityAdapter](adapter)
+	if !ok {
+		return "", VisibilityUnsupported
+	}
+	return visibilityAdapter.Visibility(path)
+}
+
+// validVisibility 校验可见性取值
+func validVisibility(visibility storage.Visibility) error {
+	switch visibility {
+	case storage.VisibilityPublic, storage.VisibilityPrivate:
+		return nil
+	}
+	return errors.Errorf("invalid visibility %q", visibility)
+}
+
+// defaultVisibility 存储桶默认可见性
+func defaultVisibility(isPrivate bool) storage.Visibility {
+	if isPrivate {
+		return storage.VisibilityPrivate
+	}
+	return storage.VisibilityPublic
+}
+
+// SetVisibility 设置挂载点内文件可见性
+func (manager *MountManager) SetVisibility(path string, visibility storage.Visibility) error {
+	mp, err := manager.resolve(path)
+	if err != nil {
+		return err
+	}
+	return SetVisibility(mp.adapter, mp.path, visibility)
+}
+
+// Visibility 获取挂载点内文件可见性
+func (manager *MountManager) Visibility(path string) (storage.Visibility, error) {
+	mp, err := manager.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	return GetVisibility(mp.adapter, mp.path)
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dysodeng/filesystem/adapter"
+	"github.com/dysodeng/filesystem/mirror"
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+// stringsFlag 可重复指定的字符串参数
+type stringsFlag []string
+
+func (values *stringsFlag) String() string {
+	return strings.Join(*values, ",")
+}
+
+func (values *stringsFlag) Set(value string) error {
+	*values = append(*values, value)
+	return nil
+}
+
+func runLs(app *app, flags *flag.FlagSet, args []string) error {
+	recursive := flags.Bool("r", false, "list recursively")
+	asJSON := flags.Bool("json", false, "print one JSON attribute per line")
+	if err := parseArgs(flags, args, 0, 1); err != nil {
+		return err
+	}
+	loc, err := app.locate(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	// 本地存储 List 递归返回全部子孙, 非递归时只保留直接子项
+	dir := strings.Trim(loc.path, "/")
+	var attributes []storage.Attribute
+	collect := func(attribute storage.Attribute) {
+		current := strings.Trim(attribute.Path(), "/")
+		if current == dir || (!*recursive && parentDir(current) != dir) {
+			return
+		}
+		attributes = append(attributes, attribute)
+	}
+	if *recursive {
+		err = adapter.Walk(loc.fs, dir, collect)
+	} else {
+		err = loc.fs.List(dir, collect)
+	}
+	if err != nil {
+		return err
+	}
+	sort.Slice(attributes, func(i, j int) bool {
+		return attributes[i].Path() < attributes[j].Path()
+	})
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, attribute := range attributes {
+			if err = encoder.Encode(attribute); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, attribute := range attributes {
+		kind, size := "d", "-"
+		if file, ok := attribute.(*storage.FileAttribute); ok {
+			kind, size = "-", fmt.Sprint(file.FileSize())
+		}
+		modified := "-"
+		if attribute.LastModified() > 0 {
+			modified = time.Unix(attribute.LastModified(), 0).Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t %s\n", kind, size, modified, loc.backend+schemeSeparator+strings.Trim(attribute.Path(), "/"))
+	}
+	return w.Flush()
+}
+
+func runStat(app *app, flags *flag.FlagSet, args []string) error {
+	if err := parseArgs(flags, args, 1, 1); err != nil {
+		return err
+	}
+	loc, err := app.locate(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	attribute, err := loc.fs.Info(loc.path)
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(attribute, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(content))
+	return nil
+}
+
+func runCat(app *app, flags *flag.FlagSet, args []string) error {
+	if err := parseArgs(flags, args, 1, -1); err != nil {
+		return err
+	}
+	for _, arg := range flags.Args() {
+		loc, err := app.locate(arg)
+		if err != nil {
+			return err
+		}
+		if err = readTo(loc, os.Stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runPut(app *app, flags *flag.FlagSet, args []string) error {
+	mimeType := flags.String("type", "", "mime type, detected from content when empty")
+	if err := parseArgs(flags, args, 2, 2); err != nil {
+		return err
+	}
+	local := flags.Arg(0)
+	loc, err := app.locate(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	var src io.Reader = os.Stdin
+	if local != "-" {
+		file, err := os.Open(local)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		src = file
+		if loc.path == "" || strings.HasSuffix(loc.path, "/") {
+			loc.path += filepath.Base(local)
+		}
+	} else if loc.path == "" || strings.HasSuffix(loc.path, "/") {
+		return errors.New("target file name is required when reading from stdin")
+	}
+
+	if err = makeParent(loc); err != nil {
+		return err
+	}
+	_, err = loc.fs.Save(loc.path, src, *mimeType)
+	return err
+}
+
+func runGet(app *app, flags *flag.FlagSet, args []string) error {
+	if err := parseArgs(flags, args, 1, 2); err != nil {
+		return err
+	}
+	loc, err := app.locate(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	local := flags.Arg(1)
+	if local == "-" {
+		return readTo(loc, os.Stdout)
+	}
+	if info, err := os.Stat(local); local == "" || (err == nil && info.IsDir()) {
+		local = filepath.Join(local, path.Base(loc.path))
+	}
+	file, err := os.Create(local)
+	if err != nil {
+		return err
+	}
+	if err = readTo(loc, file); err != nil {
+		_ = file.Close()
+		_ = os.Remove(local)
+		return err
+	}
+	return file.Close()
+}
+
+func runCp(app *app, flags *flag.FlagSet, args []string) error {
+	return transfer(app, flags, args, false)
+}
+
+func runMv(app *app, flags *flag.FlagSet, args []string) error {
+	return transfer(app, flags, args, true)
+}
+
+// transfer 复制或移动文件, -r 时处理整个目录
+func transfer(app *app, flags *flag.FlagSet, args []string, move bool) error {
+	recursive := flags.Bool("r", false, "copy directories recursively")
+	conflict := flags.String("conflict", string(adapter.ConflictOverwrite), "existing target policy with -r: overwrite, skip or fail")
+	if err := parseArgs(flags, args, 2, 2); err != nil {
+		return err
+	}
+	src, err := app.locate(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	dst, err := app.locate(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	if *recursive {
+		policy := adapter.ConflictPolicy(*conflict)
+		switch policy {
+		case adapter.ConflictOverwrite, adapter.ConflictSkip, adapter.ConflictFail:
+		default:
+			return errors.Errorf("unknown conflict policy %q", *conflict)
+		}
+		options := adapter.TreeOptions{Conflict: policy}
+		var report adapter.TreeReport
+		if move {
+			report, err = app.manager.MoveTree(src.mountPath(), dst.mountPath(), options)
+		} else {
+			report, err = app.manager.CopyTree(src.mountPath(), dst.mountPath(), options)
+		}
+		for _, failed := range report.Failed {
+			fmt.Fprintf(os.Stderr, "failed %s: %v\n", failed.Path, failed.Err)
+		}
+		fmt.Printf("%d files: %d done, %d skipped, %d failed\n", report.Total, report.Succeeded, len(report.Skipped), len(report.Failed))
+		return err
+	}
+
+	if dst.path == "" || strings.HasSuffix(dst.path, "/") {
+		dst.path += path.Base(src.path)
+	}
+	if err = makeParent(dst); err != nil {
+		return err
+	}
+	if move {
+		_, err = app.manager.Move(dst.mountPath(), src.mountPath())
+	} else {
+		_, err = app.manager.Copy(src.mountPath(), dst.mountPath())
+	}
+	return err
+}
+
+func runRm(app *app, flags *flag.FlagSet, args []string) error {
+	recursive := flags.Bool("r", false, "delete directories and their contents")
+	if err := parseArgs(flags, args, 1, -1); err != nil {
+		return err
+	}
+
+	var files []string
+	var dirs []location
+	for _, arg := range flags.Args() {
+		loc, err := app.locate(arg)
+		if err != nil {
+			return err
+		}
+		if !*recursive {
+			files = append(files, loc.mountPath())
+			continue
+		}
+		if strings.Trim(loc.path, "/") == "" {
+			return errors.Errorf("%s: refusing to remove the backend root", arg)
+		}
+		dirs = append(dirs, loc)
+		err = adapter.Walk(loc.fs, loc.path, func(attribute storage.Attribute) {
+			if attribute.IsFile() {
+				files = append(files, loc.backend+schemeSeparator+strings.Trim(attribute.Path(), "/"))
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	failed := adapter.BatchFailed(app.manager.BatchDelete(files, adapter.BatchOptions{}))
+	for _, result := range failed {
+		fmt.Fprintf(os.Stderr, "failed %s: %v\n", result.Path, result.Err)
+	}
+	for _, dir := range dirs {
+		adapter.PruneTree(dir.fs, dir.path)
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("%d of %d files could not be deleted", len(failed), len(files))
+	}
+	return nil
+}
+
+func runMkdir(app *app, flags *flag.FlagSet, args []string) error {
+	if err := parseArgs(flags, args, 1, -1); err != nil {
+		return err
+	}
+	for _, arg := range flags.Args() {
+		loc, err := app.locate(arg)
+		if err != nil {
+			return err
+		}
+		if _, err = loc.fs.MkDir(loc.path, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runSign(app *app, flags *flag.FlagSet, args []string) error {
+	method := flags.String("method", "GET", "http method: GET, PUT, HEAD or DELETE")
+	expires := flags.Duration("expires", time.Hour, "url lifetime, at most 168h")
+	if err := parseArgs(flags, args, 1, 1); err != nil {
+		return err
+	}
+	loc, err := app.locate(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	signed, err := adapter.SignURL(loc.fs, loc.path, *method, *expires)
+	if err != nil {
+		return err
+	}
+	fmt.Println(signed)
+	return nil
+}
+
+func runSync(app *app, flags *flag.FlagSet, args []string) error {
+	var include, exclude stringsFlag
+	flags.Var(&include, "include", "only sync files matching the glob, repeatable")
+	flags.Var(&exclude, "exclude", "skip files matching the glob, repeatable")
+	dryRun := flags.Bool("dry-run", false, "print the plan without changing anything")
+	deleteExtraneous := flags.Bool("delete", false, "delete target files missing from the source")
+	twoWay := flags.Bool("two-way", false, "sync in both directions, newer files win")
+	ignoreETag := flags.Bool("ignore-etag", false, "compare by size and modification time only")
+	concurrency := flags.Int("concurrency", 8, "number of concurrent transfers")
+	verbose := flags.Bool("v", false, "print each transferred file")
+	if err := parseArgs(flags, args, 2, 2); err != nil {
+		return err
+	}
+	src, err := app.locate(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	dst, err := app.locate(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	options := mirror.Options{
+		Mode:             mirror.ModeMirror,
+		DryRun:           *dryRun,
+		Include:          include,
+		Exclude:          exclude,
+		DeleteExtraneous: *deleteExtraneous,
+		IgnoreETag:       *ignoreETag,
+		Concurrency:      *concurrency,
+	}
+	if *twoWay {
+		options.Mode = mirror.ModeTwoWay
+	}
+	if *verbose {
+		options.Progress = func(task mirror.Task, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed %s %s: %v\n", task.Action, task.Path, err)
+				return
+			}
+			fmt.Printf("%s %s\n", task.Action, task.Path)
+		}
+	}
+
+	summary, err := mirror.Run(src.fs, src.path, dst.fs, dst.path, options)
+	if *dryRun {
+		for _, task := range summary.Plan {
+			if task.Action != mirror.ActionSkip {
+				fmt.Printf("%s %s (%s)\n", task.Action, task.Path, task.Reason)
+			}
+		}
+	} else if !*verbose {
+		for _, failed := range summary.Failed {
+			fmt.Fprintf(os.Stderr, "failed %s: %v\n", failed.Path, failed.Err)
+		}
+	}
+	if err == nil || errors.Is(err, mirror.Incomplete) {
+		fmt.Println(summary)
+	}
+	return err
+}
+
+// readTo 读取文件写入w
+func readTo(loc location, w io.Writer) error {
+	reader, err := loc.fs.Read(loc.path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+// makeParent 本地存储写入前需创建上级目录, 云存储上为空操作
+func makeParent(loc location) error {
+	dir := parentDir(loc.path)
+	if dir == "" {
+		return nil
+	}
+	_, err := loc.fs.MkDir(dir, 0755)
+	return err
+}
+
+// parentDir 上级目录, 根目录下的文件返回空
+func parentDir(file string) string {
+	dir := path.Dir(strings.Trim(file, "/"))
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
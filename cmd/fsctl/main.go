@@ -0,0 +1,160 @@
+// fsctl 通过统一的存储适配器操作本地存储、MinIO、阿里云OSS、腾讯云COS、华为云OBS
+//
+//	fsctl [-config file] <command> [flags] [args]
+//
+// 路径格式为 <backend>://<path>, 省略后端时使用配置中的默认后端, 配置格式见 config
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dysodeng/filesystem/adapter"
+	"github.com/pkg/errors"
+)
+
+// errUsage 参数错误, 用法已输出
+var errUsage = errors.New("usage")
+
+// command 子命令
+type command struct {
+	synopsis string // 参数说明
+	summary  string // 命令说明
+	run      func(app *app, flags *flag.FlagSet, args []string) error
+}
+
+var commands = map[string]command{
+	"ls":    {"[-r] [-json] [path]", "list files and directories", runLs},
+	"stat":  {"<path>", "print file attributes as JSON", runStat},
+	"cat":   {"<path>...", "write file contents to stdout", runCat},
+	"put":   {"[-type mime] <local|-> <path>", "upload a local file or stdin", runPut},
+	"get":   {"<path> [local|-]", "download a file to local disk or stdout", runGet},
+	"cp":    {"[-r] [-conflict policy] <src> <dst>", "copy files, across backends if needed", runCp},
+	"mv":    {"[-r] [-conflict policy] <src> <dst>", "move files, across backends if needed", runMv},
+	"rm":    {"[-r] <path>...", "delete files or, with -r, directories", runRm},
+	"mkdir": {"<dir>...", "create directories", runMkdir},
+	"sign":  {"[-method GET] [-expires 1h] <path>", "print a presigned url", runSign},
+	"sync":  {"[flags] <src> <dst>", "mirror or two-way sync two directories", runSync},
+}
+
+// app 命令执行环境, 后端在首次使用时创建并挂载到挂载管理器
+type app struct {
+	config  *config
+	manager *adapter.MountManager
+	opened  map[string]adapter.Adapter
+}
+
+// location 命令行中的存储路径
+type location struct {
+	backend string
+	fs      adapter.Adapter
+	path    string // 适配器内路径
+}
+
+// mountPath 挂载管理器路径
+func (loc location) mountPath() string {
+	return loc.backend + schemeSeparator + loc.path
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	flags := flag.NewFlagSet("fsctl", flag.ContinueOnError)
+	configFlag := flags.String("config", "", "config file (default $FSCTL_CONFIG or ~/.fsctl.yaml)")
+	flags.Usage = func() {
+		usage(flags.Output())
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	name := flags.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "fsctl: unknown command %q\n", name)
+		usage(os.Stderr)
+		return 2
+	}
+
+	cfg, err := loadConfig(configPath(*configFlag))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fsctl:", err)
+		return 1
+	}
+	app := &app{config: cfg, manager: adapter.NewMountManager(), opened: make(map[string]adapter.Adapter)}
+
+	cmdFlags := flag.NewFlagSet(name, flag.ContinueOnError)
+	cmdFlags.Usage = func() {
+		fmt.Fprintf(cmdFlags.Output(), "usage: fsctl %s %s\n", name, cmd.synopsis)
+		cmdFlags.PrintDefaults()
+	}
+	if err = cmd.run(app, cmdFlags, flags.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		fmt.Fprintf(os.Stderr, "fsctl %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// usage 输出命令列表
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: fsctl [-config file] <command> [flags] [args]")
+	fmt.Fprintln(w, "\npaths are <backend>://<path>, or <path> on the default backend\n\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-6s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w)
+}
+
+// parseArgs 解析子命令参数并校验位置参数个数, max小于0时不限
+func parseArgs(flags *flag.FlagSet, args []string, min, max int) error {
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() < min || (max >= 0 && flags.NArg() > max) {
+		flags.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// locate 解析存储路径, 首次使用的后端会被创建并挂载
+func (app *app) locate(arg string) (location, error) {
+	name, path := app.config.Default, arg
+	if i := strings.Index(arg, schemeSeparator); i > 0 {
+		name, path = arg[:i], arg[i+len(schemeSeparator):]
+	} else if name == "" {
+		return location{}, errors.Errorf("%q: no backend given and no default backend configured", arg)
+	}
+
+	fs, ok := app.opened[name]
+	if !ok {
+		var err error
+		if fs, err = app.config.open(name); err != nil {
+			return location{}, err
+		}
+		if err = app.manager.Mount(name, fs); err != nil {
+			return location{}, err
+		}
+		app.opened[name] = fs
+	}
+	return location{backend: name, fs: fs, path: strings.TrimLeft(path, "/")}, nil
+}
@@ -0,0 +1,58 @@
+package mirror
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// filter include/exclude 过滤规则
+// glob 语法同 path.Match; 不含"/"的glob匹配文件名或任一上级目录名, 否则匹配相对路径或任一上级目录
+type filter struct {
+	include []string
+	exclude []string
+}
+
+// newFilter 校验并创建过滤规则
+func newFilter(include, exclude []string) (filter, error) {
+	matcher := filter{}
+	for _, pattern := range include {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return filter{}, errors.Wrapf(err, "include pattern %q", pattern)
+		}
+		matcher.include = append(matcher.include, strings.Trim(pattern, "/"))
+	}
+	for _, pattern := range exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return filter{}, errors.Wrapf(err, "exclude pattern %q", pattern)
+		}
+		matcher.exclude = append(matcher.exclude, strings.Trim(pattern, "/"))
+	}
+	return matcher, nil
+}
+
+// match 判断相对路径是否参与同步
+func (matcher filter) match(rel string) bool {
+	if len(matcher.include) > 0 && !matchAny(matcher.include, rel) {
+		return false
+	}
+	return !matchAny(matcher.exclude, rel)
+}
+
+// matchAny 路径或其任一上级目录匹配任一glob时返回true
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		byName := !strings.Contains(pattern, "/")
+		for current := rel; current != "." && current != ""; current = path.Dir(current) {
+			name := current
+			if byName {
+				name = path.Base(current)
+			}
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
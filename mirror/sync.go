@@ -0,0 +1,299 @@
+// Package mirror 在任意两个存储适配器之间同步目录
+// 按大小、修改时间、ETag比较两端文件, 生成上传、删除、跳过计划后并发执行
+package mirror
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dysodeng/filesystem/adapter"
+	"github.com/dysodeng/filesystem/storage"
+	"github.com/pkg/errors"
+)
+
+var (
+	Incomplete = errors.New("sync partially failed")
+)
+
+// Action 同步动作
+type Action string
+
+const (
+	ActionUpload   Action = "upload"   // 源文件复制到目标端
+	ActionDownload Action = "download" // 目标端文件复制到源端, 仅双向同步
+	ActionDelete   Action = "delete"   // 删除目标端多余文件
+	ActionSkip     Action = "skip"     // 两端一致, 跳过
+)
+
+// Mode 同步方式
+type Mode string
+
+const (
+	ModeMirror Mode = "mirror"  // 单向镜像, 以源端为准
+	ModeTwoWay Mode = "two-way" // 双向同步, 以较新的文件为准
+)
+
+// 生成计划的原因
+const (
+	reasonMissing    = "missing"
+	reasonSize       = "size differs"
+	reasonETag       = "etag differs"
+	reasonNewer      = "newer"
+	reasonExtraneous = "extraneous"
+	reasonUnchanged  = "unchanged"
+)
+
+type Options struct {
+	Mode             Mode                       // 同步方式, 默认单向镜像
+	DryRun           bool                       // 只生成计划不执行
+	Include          []string                   // 包含的文件glob, 为空时包含全部
+	Exclude          []string                   // 排除的文件glob, 优先于Include, 被排除的目标端文件不会删除
+	DeleteExtraneous bool                       // 删除目标端多余文件, 仅单向镜像
+	IgnoreETag       bool                       // 不比较ETag, 仅比较大小与修改时间
+	Concurrency      int                        // 并发数, 默认8
+	Progress         func(task Task, err error) // 进度回调, 每执行完一个任务调用一次, 不会并发调用
+}
+
+// Task 同步计划中的单个文件
+type Task struct {
+	Action Action // 同步动作
+	Path   string // 相对同步目录的路径
+	Size   int64  // 需要传输的字节数
+	Reason string // 原因
+}
+
+// Summary 同步结果, DryRun时各项计数为计划数量
+type Summary struct {
+	DryRun     bool
+	Plan       []Task                // 同步计划, 按路径排序
+	Uploaded   int                   // 复制到目标端的文件数
+	Downloaded int                   // 复制到源端的文件数
+	Deleted    int                   // 删除的目标端文件数
+	Skipped    int                   // 跳过的文件数
+	Bytes      int64                 // 传输字节数
+	Failed     []adapter.BatchResult // 失败的文件, 路径为相对路径
+	Duration   time.Duration
+}
+
+// String 同步结果摘要
+func (summary Summary) String() string {
+	s := fmt.Sprintf("uploaded %d, downloaded %d, deleted %d, skipped %d, failed %d, %d bytes in %s",
+		summary.Uploaded, summary.Downloaded, summary.Deleted, summary.Skipped, len(summary.Failed),
+		summary.Bytes, summary.Duration.Round(time.Millisecond))
+	if summary.DryRun {
+		s += " (dry run)"
+	}
+	return s
+}
+
+// Plan 比较源目录与目标目录, 生成同步计划
+// 大小不同时同步; 大小相同且两端ETag可比较时按ETag判断; 否则单向镜像在源文件较新时同步, 双向同步视为一致
+// 目标目录不存在时视为空目录, 目录本身不参与同步
+// @param src adapter.Adapter 源适配器
+// @param srcDir string 源目录
+// @param dst adapter.Adapter 目标适配器
+// @param dstDir string 目标目录
+// @param options Options 同步选项
+func Plan(src adapter.Adapter, srcDir string, dst adapter.Adapter, dstDir string, options Options) ([]Task, error) {
+	switch options.Mode {
+	case "", ModeMirror:
+	case ModeTwoWay:
+		if options.DeleteExtraneous {
+			return nil, errors.New("delete extraneous is not supported in two-way mode")
+		}
+	default:
+		return nil, errors.Errorf("unknown sync mode %q", options.Mode)
+	}
+	matcher, err := newFilter(options.Include, options.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	srcFiles, err := listFiles(src, srcDir, matcher)
+	if err != nil {
+		return nil, errors.Wrap(err, "list source")
+	}
+	dstFiles, err := listFiles(dst, dstDir, matcher)
+	if err != nil && !errors.Is(err, adapter.FileNotExists) {
+		return nil, errors.Wrap(err, "list target")
+	}
+
+	var tasks []Task
+	for rel, file := range srcFiles {
+		if target, ok := dstFiles[rel]; ok {
+			tasks = append(tasks, compare(rel, file, target, options))
+		} else {
+			tasks = append(tasks, Task{Action: ActionUpload, Path: rel, Size: file.FileSize(), Reason: reasonMissing})
+		}
+	}
+	for rel, file := range dstFiles {
+		if _, ok := srcFiles[rel]; ok {
+			continue
+		}
+		switch {
+		case options.Mode == ModeTwoWay:
+			tasks = append(tasks, Task{Action: ActionDownload, Path: rel, Size: file.FileSize(), Reason: reasonMissing})
+		case options.DeleteExtraneous:
+			tasks = append(tasks, Task{Action: ActionDelete, Path: rel, Reason: reasonExtraneous})
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Path < tasks[j].Path
+	})
+	return tasks, nil
+}
+
+// Run 生成同步计划并执行, 部分文件失败时返回 Incomplete, 失败明细见 Summary.Failed
+// @param src adapter.Adapter 源适配器
+// @param srcDir string 源目录
+// @param dst adapter.Adapter 目标适配器
+// @param dstDir string 目标目录
+// @param options Options 同步选项
+func Run(src adapter.Adapter, srcDir string, dst adapter.Adapter, dstDir string, options Options) (Summary, error) {
+	start := time.Now()
+	plan, err := Plan(src, srcDir, dst, dstDir, options)
+	if err != nil {
+		return Summary{}, err
+	}
+	srcDir, dstDir = strings.Trim(srcDir, "/"), strings.Trim(dstDir, "/")
+
+	summary := Summary{DryRun: options.DryRun, Plan: plan}
+	var pending []Task
+	for _, task := range plan {
+		if task.Action == ActionSkip {
+			summary.Skipped++
+			continue
+		}
+		if options.DryRun {
+			summary.count(task)
+			continue
+		}
+		pending = append(pending, task)
+	}
+	if len(pending) > 0 {
+		if err = makeDirs(src, srcDir, dst, dstDir, pending); err != nil {
+			return summary, err
+		}
+	}
+
+	var mu sync.Mutex
+	adapter.RunBatch(len(pending), adapter.BatchOptions{Concurrency: options.Concurrency}, func(i int) {
+		task := pending[i]
+		srcFile, dstFile := path.Join(srcDir, task.Path), path.Join(dstDir, task.Path)
+		var err error
+		switch task.Action {
+		case ActionUpload:
+			_, err = adapter.CopyAcross(src, srcFile, dst, dstFile)
+		case ActionDownload:
+			_, err = adapter.CopyAcross(dst, dstFile, src, srcFile)
+		case ActionDelete:
+			_, err = dst.Delete(dstFile)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			summary.Failed = append(summary.Failed, adapter.BatchResult{Path: task.Path, Err: err})
+		} else {
+			summary.count(task)
+		}
+		if options.Progress != nil {
+			options.Progress(task, err)
+		}
+	})
+
+	summary.Duration = time.Since(start)
+	if len(summary.Failed) > 0 {
+		return summary, errors.Wrapf(Incomplete, "%d of %d tasks failed", len(summary.Failed), len(pending))
+	}
+	return summary, nil
+}
+
+// count 计入已完成的任务
+func (summary *Summary) count(task Task) {
+	switch task.Action {
+	case ActionUpload:
+		summary.Uploaded++
+	case ActionDownload:
+		summary.Downloaded++
+	case ActionDelete:
+		summary.Deleted++
+	}
+	summary.Bytes += task.Size
+}
+
+// compare 比较两端同名文件
+func compare(rel string, src, dst *storage.FileAttribute, options Options) Task {
+	transfer := func(reason string) Task {
+		if options.Mode == ModeTwoWay && dst.LastModified() > src.LastModified() {
+			return Task{Action: ActionDownload, Path: rel, Size: dst.FileSize(), Reason: reason}
+		}
+		return Task{Action: ActionUpload, Path: rel, Size: src.FileSize(), Reason: reason}
+	}
+
+	switch {
+	case src.FileSize() != dst.FileSize():
+		return transfer(reasonSize)
+	case !options.IgnoreETag && comparableETag(src.ETag(), dst.ETag()):
+		if !strings.EqualFold(src.ETag(), dst.ETag()) {
+			return transfer(reasonETag)
+		}
+	case options.Mode != ModeTwoWay && src.LastModified() > dst.LastModified():
+		return transfer(reasonNewer)
+	}
+	return Task{Action: ActionSkip, Path: rel, Reason: reasonUnchanged}
+}
+
+// comparableETag 两端ETag均为内容MD5时才可比较, 分片上传的ETag带有"-"
+func comparableETag(a, b string) bool {
+	return a != "" && b != "" && !strings.Contains(a, "-") && !strings.Contains(b, "-")
+}
+
+// listFiles 递归列出目录下匹配过滤规则的文件, 键为相对路径
+func listFiles(fs adapter.Adapter, dir string, matcher filter) (map[string]*storage.FileAttribute, error) {
+	dir = strings.Trim(dir, "/")
+	files := make(map[string]*storage.FileAttribute)
+	err := adapter.Walk(fs, dir, func(attribute storage.Attribute) {
+		file, ok := attribute.(*storage.FileAttribute)
+		if !ok {
+			return
+		}
+		rel := strings.TrimLeft(strings.TrimPrefix(strings.Trim(file.Path(), "/"), dir), "/")
+		if matcher.match(rel) {
+			files[rel] = file
+		}
+	})
+	return files, err
+}
+
+// makeDirs 本地存储写入前需创建上级目录, 云存储上为空操作
+func makeDirs(src adapter.Adapter, srcDir string, dst adapter.Adapter, dstDir string, tasks []Task) error {
+	srcDirs, dstDirs := map[string]bool{}, map[string]bool{}
+	for _, task := range tasks {
+		switch task.Action {
+		case ActionUpload:
+			dstDirs[path.Dir(path.Join(dstDir, task.Path))] = true
+		case ActionDownload:
+			srcDirs[path.Dir(path.Join(srcDir, task.Path))] = true
+		}
+	}
+	// 源端与目标端可能是同一适配器, 不能以适配器为键合并
+	for _, side := range []struct {
+		fs   adapter.Adapter
+		dirs map[string]bool
+	}{{src, srcDirs}, {dst, dstDirs}} {
+		for dir := range side.dirs {
+			if dir == "." {
+				continue
+			}
+			if _, err := side.fs.MkDir(dir, 0755); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package mirror
+
+import (
+	"bytes"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dysodeng/filesystem/adapter"
+	"github.com/dysodeng/filesystem/storage"
+)
+
+// pagedAdapter 按页返回列举结果的模拟适配器, 只实现List
+type pagedAdapter struct {
+	adapter.Adapter
+	files    map[string]int64 // 文件路径及大小
+	pageSize int
+	pages    int // 已请求的页数
+}
+
+// page 返回marker之后的一页文件及下一页的marker, 最后一页marker为空
+func (fs *pagedAdapter) page(dir, marker string) ([]string, string) {
+	var keys []string
+	for key := range fs.files {
+		if strings.HasPrefix(key, dir+"/") && key > marker {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	fs.pages++
+	if len(keys) <= fs.pageSize {
+		return keys, ""
+	}
+	return keys[:fs.pageSize], keys[fs.pageSize-1]
+}
+
+func (fs *pagedAdapter) List(dir string, iterable func(attribute storage.Attribute)) error {
+	dir = strings.Trim(dir, "/")
+	for marker := ""; ; {
+		keys, next := fs.page(dir, marker)
+		for _, key := range keys {
+			iterable(storage.NewFileAttribute(path.Base(key), key, storage.VisibilityPrivate, "text/plain", fs.files[key], 0))
+		}
+		if next == "" {
+			return nil
+		}
+		marker = next
+	}
+}
+
+func TestPlanListsEveryPage(t *testing.T) {
+	src := &pagedAdapter{pageSize: 2, files: map[string]int64{
+		"src/a.txt": 1, "src/b.txt": 2, "src/c.txt": 3, "src/d.txt": 4, "src/e.txt": 5,
+	}}
+	dst := &pagedAdapter{pageSize: 2, files: map[string]int64{
+		"dst/a.txt": 1, "dst/e.txt": 5, "dst/z.txt": 9,
+	}}
+
+	tasks, err := Plan(src, "src", dst, "dst", Options{DeleteExtraneous: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.pages < 2 || dst.pages < 2 {
+		t.Fatalf("listed %d source and %d target pages, want several", src.pages, dst.pages)
+	}
+
+	want := []Task{
+		{Action: ActionSkip, Path: "a.txt", Reason: reasonUnchanged},
+		{Action: ActionUpload, Path: "b.txt", Size: 2, Reason: reasonMissing},
+		{Action: ActionUpload, Path: "c.txt", Size: 3, Reason: reasonMissing},
+		{Action: ActionUpload, Path: "d.txt", Size: 4, Reason: reasonMissing},
+		{Action: ActionSkip, Path: "e.txt", Reason: reasonUnchanged},
+		{Action: ActionDelete, Path: "z.txt", Reason: reasonExtraneous},
+	}
+	if len(tasks) != len(want) {
+		t.Fatalf("got %d tasks %v, want %d", len(tasks), tasks, len(want))
+	}
+	for i := range want {
+		if tasks[i] != want[i] {
+			t.Errorf("task %d: got %+v, want %+v", i, tasks[i], want[i])
+		}
+	}
+}
+
+func TestRunTwoWayWithinOneAdapter(t *testing.T) {
+	fs := adapter.NewLocalAdapter(adapter.LocalConfig{BasePath: t.TempDir()})
+	for _, file := range []string{"left/one/a.txt", "right/two/b.txt"} {
+		if _, err := fs.MkDir(path.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.Save(file, bytes.NewReader([]byte(file)), "text/plain"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 源端与目标端为同一适配器时, 两端缺失的上级目录都需创建
+	summary, err := Run(fs, "left", fs, "right", Options{Mode: ModeTwoWay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Uploaded != 1 || summary.Downloaded != 1 {
+		t.Fatalf("got %s, want one upload and one download", summary)
+	}
+	for _, file := range []string{"left/two/b.txt", "right/one/a.txt"} {
+		if !fs.HasFile(file) {
+			t.Errorf("%s was not synced", file)
+		}
+	}
+}
@@ -42,6 +42,20 @@ type FileAttribute struct {
 	lastModified int64
 	visibility   Visibility
 	mimeType     string
+
+	serverSideEncryption string
+	kmsKeyID             string
+
+	etag               string
+	contentMD5         string // base64编码
+	crc64              string // CRC64-ECMA, 十进制
+	sha256             string // base64编码
+	storageClass       string
+	cacheControl       string
+	contentDisposition string
+	contentEncoding    string
+	metadata           map[string]string
+	versionID          string
 }
 
 type jsonAttr struct {
@@ -52,6 +66,20 @@ type jsonAttr struct {
 	LastModified int64      `json:"last_modified"`
 	Visibility   Visibility `json:"visibility"`
 	MimeType     string     `json:"mime_type"`
+
+	ServerSideEncryption string `json:"server_side_encryption,omitempty"`
+	KMSKeyID             string `json:"kms_key_id,omitempty"`
+
+	ETag               string            `json:"etag,omitempty"`
+	ContentMD5         string            `json:"content_md5,omitempty"`
+	CRC64              string            `json:"crc64,omitempty"`
+	SHA256             string            `json:"sha256,omitempty"`
+	StorageClass       string            `json:"storage_class,omitempty"`
+	CacheControl       string            `json:"cache_control,omitempty"`
+	ContentDisposition string            `json:"content_disposition,omitempty"`
+	ContentEncoding    string            `json:"content_encoding,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	VersionID          string            `json:"version_id,omitempty"`
 }
 
 func NewFileAttribute(name, path string, visibility Visibility, mimeType string, fileSize, lastModified int64) *FileAttribute {
@@ -96,6 +124,154 @@ func (file *FileAttribute) MimeType() string {
 	return file.mimeType
 }
 
+// ServerSideEncryption 服务端加密方式, 未加密时为空
+func (file *FileAttribute) ServerSideEncryption() string {
+	return file.serverSideEncryption
+}
+
+// KMSKeyID SSE-KMS 加密使用的密钥ID
+func (file *FileAttribute) KMSKeyID() string {
+	return file.kmsKeyID
+}
+
+// ETag 实体标签
+func (file *FileAttribute) ETag() string {
+	return file.etag
+}
+
+// ContentMD5 内容MD5, base64编码
+func (file *FileAttribute) ContentMD5() string {
+	return file.contentMD5
+}
+
+// CRC64 内容CRC64-ECMA校验值, 十进制
+func (file *FileAttribute) CRC64() string {
+	return file.crc64
+}
+
+// SHA256 内容SHA-256, base64编码
+func (file *FileAttribute) SHA256() string {
+	return file.sha256
+}
+
+// StorageClass 存储类型
+func (file *FileAttribute) StorageClass() string {
+	return file.storageClass
+}
+
+// CacheControl 缓存控制
+func (file *FileAttribute) CacheControl() string {
+	return file.cacheControl
+}
+
+// ContentDisposition 内容展示方式
+func (file *FileAttribute) ContentDisposition() string {
+	return file.contentDisposition
+}
+
+// ContentEncoding 内容编码, 如gzip
+func (file *FileAttribute) ContentEncoding() string {
+	return file.contentEncoding
+}
+
+// Metadata 用户自定义元数据
+func (file *FileAttribute) Metadata() map[string]string {
+	return file.metadata
+}
+
+// VersionID 版本ID
+func (file *FileAttribute) VersionID() string {
+	return file.versionID
+}
+
+// WithPath 设置文件路径并返回自身
+func (file *FileAttribute) WithPath(path string) *FileAttribute {
+	file.path = path
+	return file
+}
+
+// WithFileSize 设置文件大小并返回自身
+func (file *FileAttribute) WithFileSize(fileSize int64) *FileAttribute {
+	file.fileSize = fileSize
+	return file
+}
+
+// WithMimeType 设置文件类型并返回自身
+func (file *FileAttribute) WithMimeType(mimeType string) *FileAttribute {
+	file.mimeType = mimeType
+	return file
+}
+
+// WithServerSideEncryption 设置服务端加密方式并返回自身
+func (file *FileAttribute) WithServerSideEncryption(algorithm, kmsKeyID string) *FileAttribute {
+	file.serverSideEncryption = algorithm
+	file.kmsKeyID = kmsKeyID
+	return file
+}
+
+// WithETag 设置实体标签并返回自身
+func (file *FileAttribute) WithETag(etag string) *FileAttribute {
+	file.etag = etag
+	return file
+}
+
+// WithChecksums 设置内容校验值并返回自身, 为空的校验值保持不变
+// @param md5 string base64编码的MD5
+// @param crc64 string 十进制的CRC64-ECMA
+// @param sha256 string base64编码的SHA-256
+func (file *FileAttribute) WithChecksums(md5, crc64, sha256 string) *FileAttribute {
+	if md5 != "" {
+		file.contentMD5 = md5
+	}
+	if crc64 != "" {
+		file.crc64 = crc64
+	}
+	if sha256 != "" {
+		file.sha256 = sha256
+	}
+	return file
+}
+
+// WithoutChecksums 清除ETag与内容校验值并返回自身, 供改写内容的装饰器使用
+func (file *FileAttribute) WithoutChecksums() *FileAttribute {
+	file.etag = ""
+	file.contentMD5 = ""
+	file.crc64 = ""
+	file.sha256 = ""
+	return file
+}
+
+// WithStorageClass 设置存储类型并返回自身
+func (file *FileAttribute) WithStorageClass(storageClass string) *FileAttribute {
+	file.storageClass = storageClass
+	return file
+}
+
+// WithContentHeaders 设置缓存控制与内容展示方式并返回自身
+func (file *FileAttribute) WithContentHeaders(cacheControl, contentDisposition string) *FileAttribute {
+	file.cacheControl = cacheControl
+	file.contentDisposition = contentDisposition
+	return file
+}
+
+// WithContentEncoding 设置内容编码并返回自身
+func (file *FileAttribute) WithContentEncoding(contentEncoding string) *FileAttribute {
+	file.contentEncoding = contentEncoding
+	return file
+}
+
+// WithMetadata 设置用户自定义元数据并返回自身
+func (file *FileAttribute) WithMetadata(metadata map[string]string) *FileAttribute {
+	file.metadata = metadata
+	return file
+}
+
+// WithVersionID 设置版本ID并返回自身
+func (file *FileAttribute) WithVersionID(versionID string) *FileAttribute {
+	file.versionID = versionID
+	return file
+}
+
 func (file *FileAttribute) IsFile() bool {
 	return true
 }
@@ -105,7 +281,7 @@ func (file *FileAttribute) IsDir() bool {
 }
 
 func (file *FileAttribute) MarshalJSON() ([]byte, error) {
-	return json.Marshal(map[string]interface{}{
+	m := map[string]interface{}{
 		"name":          file.name,
 		"path":          file.path,
 		"type":          file.Type(),
@@ -113,7 +289,29 @@ func (file *FileAttribute) MarshalJSON() ([]byte, error) {
 		"last_modified": file.lastModified,
 		"visibility":    file.visibility,
 		"mime_type":     file.mimeType,
-	})
+	}
+	optional := map[string]string{
+		"server_side_encryption": file.serverSideEncryption,
+		"kms_key_id":             file.kmsKeyID,
+		"etag":                   file.etag,
+		"content_md5":            file.contentMD5,
+		"crc64":                  file.crc64,
+		"sha256":                 file.sha256,
+		"storage_class":          file.storageClass,
+		"cache_control":          file.cacheControl,
+		"content_disposition":    file.contentDisposition,
+		"content_encoding":       file.contentEncoding,
+		"version_id":             file.versionID,
+	}
+	for key, value := range optional {
+		if value != "" {
+			m[key] = value
+		}
+	}
+	if len(file.metadata) > 0 {
+		m["metadata"] = file.metadata
+	}
+	return json.Marshal(m)
 }
 
 func (file *FileAttribute) UnmarshalJSON(data []byte) error {
@@ -133,6 +331,18 @@ func (file *FileAttribute) UnmarshalJSON(data []byte) error {
 	file.lastModified = m.LastModified
 	file.visibility = m.Visibility
 	file.mimeType = m.MimeType
+	file.serverSideEncryption = m.ServerSideEncryption
+	file.kmsKeyID = m.KMSKeyID
+	file.etag = m.ETag
+	file.contentMD5 = m.ContentMD5
+	file.crc64 = m.CRC64
+	file.sha256 = m.SHA256
+	file.storageClass = m.StorageClass
+	file.cacheControl = m.CacheControl
+	file.contentDisposition = m.ContentDisposition
+	file.contentEncoding = m.ContentEncoding
+	file.metadata = m.Metadata
+	file.versionID = m.VersionID
 	return nil
 }
 
@@ -175,6 +385,12 @@ func (dir *DirectoryAttribute) LastModified() int64 {
 	return dir.lastModified
 }
 
+// WithPath 设置目录路径并返回自身
+func (dir *DirectoryAttribute) WithPath(path string) *DirectoryAttribute {
+	dir.path = strings.TrimRight(path, "/")
+	return dir
+}
+
 func (dir *DirectoryAttribute) IsFile() bool {
 	return false
 }